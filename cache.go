@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// downloadCache keeps downloaded videos in dir/<videoID>/<itag>. If the cache
+// grows beyond maxSize bytes, the least recently used videos are removed.
+type downloadCache struct {
+	dir     string
+	maxSize int64 // 0 for no limit
+}
+
+// videoCache is used for all downloads, it is disabled if dir is empty.
+var videoCache downloadCache
+
+type cacheEntry struct {
+	videoID string
+	itag    string
+	path    string
+	size    int64
+	used    time.Time
+}
+
+func defaultCacheDir() string {
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, appName)
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".cache", appName)
+}
+
+func (c downloadCache) enabled() bool {
+	return c.dir != ""
+}
+
+func (c downloadCache) path(videoID string, itag int) string {
+	return filepath.Join(c.dir, videoID, strconv.Itoa(itag))
+}
+
+// get returns the path of a completely downloaded video and marks it as
+// used.
+func (c downloadCache) get(videoID string, itag int) (string, bool) {
+	if !c.enabled() {
+		return "", false
+	}
+
+	path := c.path(videoID, itag)
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return path, true
+}
+
+// entries returns all cached videos, partial downloads included.
+func (c downloadCache) entries() ([]cacheEntry, error) {
+	var entries []cacheEntry
+
+	videos, err := ioutil.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read the cache directory")
+	}
+
+	for _, v := range videos {
+		// hidden directories hold other caches, e.g. musicbrainz responses
+		if !v.IsDir() || strings.HasPrefix(v.Name(), ".") {
+			continue
+		}
+		files, err := ioutil.ReadDir(filepath.Join(c.dir, v.Name()))
+		if err != nil {
+			return nil, errors.Wrap(err, "couldn't read the cache directory")
+		}
+		for _, f := range files {
+			entries = append(entries, cacheEntry{
+				videoID: v.Name(),
+				itag:    f.Name(),
+				path:    filepath.Join(c.dir, v.Name(), f.Name()),
+				size:    f.Size(),
+				used:    f.ModTime(),
+			})
+		}
+	}
+	return entries, nil
+}
+
+// evict removes the least recently used videos until the cache fits into
+// maxSize. keep is never removed.
+func (c downloadCache) evict(keep string) error {
+	if !c.enabled() || c.maxSize <= 0 {
+		return nil
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	for _, e := range entries {
+		size += e.size
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].used.Before(entries[j].used)
+	})
+	for _, e := range entries {
+		if size <= c.maxSize {
+			break
+		}
+		if e.path == keep {
+			continue
+		}
+		if err := os.Remove(e.path); err != nil {
+			return errors.Wrap(err, "couldn't remove cached video")
+		}
+		os.Remove(filepath.Dir(e.path)) // only succeeds if it's empty
+		size -= e.size
+	}
+	return nil
+}
+
+// purge removes the given videos or the whole cache if no videos are given.
+func (c downloadCache) purge(videoIDs ...string) error {
+	if len(videoIDs) == 0 {
+		return os.RemoveAll(c.dir)
+	}
+	for _, id := range videoIDs {
+		if err := os.RemoveAll(filepath.Join(c.dir, filepath.Base(id))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c downloadCache) list() error {
+	entries, err := c.entries()
+	if err != nil {
+		return err
+	}
+
+	var size int64
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "video\titag\tsize\tlast used")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%.1f MB\t%s\n", e.videoID, e.itag, float64(e.size)/(1<<20), e.used.Format("2006-01-02 15:04"))
+		size += e.size
+	}
+	w.Flush()
+	fmt.Printf("\n%d videos, %.1f MB in %s\n", len(entries), float64(size)/(1<<20), c.dir)
+	return nil
+}
+
+// runCacheCommand runs the cache subcommand.
+func runCacheCommand(args []string) error {
+	if !videoCache.enabled() {
+		return errors.New("the cache is disabled")
+	}
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+	switch strings.ToLower(args[0]) {
+	case "list", "ls":
+		return videoCache.list()
+	case "purge", "clear":
+		return videoCache.purge(args[1:]...)
+	}
+	return errors.Errorf("unknown cache command %q, use list or purge", args[0])
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/pkg/errors"
+)
+
+// defaultConfigFile returns the path of the config file. It can be changed
+// with the YMDL_CONFIG environment variable.
+func defaultConfigFile() string {
+	if path := os.Getenv("YMDL_CONFIG"); path != "" {
+		return path
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, appName, "config")
+	}
+	home, err := homedir.Dir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", appName, "config")
+}
+
+// envName returns the environment variable of a flag, e.g. YMDL_MB_URL for
+// -mb-url.
+func envName(flagName string) string {
+	return "YMDL_" + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// loadConfig sets the flags from the config file and the environment. It has
+// to be called before flag.Parse, so the command line overrides both.
+//
+// The config file has one "flag = value" per line, lines starting with # are
+// ignored. A missing config file is not an error.
+func loadConfig(path string) error {
+	if path != "" {
+		if err := readConfigFile(path); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	flag.VisitAll(func(f *flag.Flag) {
+		if v, ok := os.LookupEnv(envName(f.Name)); ok && err == nil {
+			if e := flag.Set(f.Name, v); e != nil {
+				err = errors.Wrapf(e, "invalid value %q for %s", v, envName(f.Name))
+			}
+		}
+	})
+	return err
+}
+
+func readConfigFile(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "couldn't open the config file")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return errors.Errorf("%s:%d: expected flag = value", path, n)
+		}
+		name := strings.TrimLeft(strings.TrimSpace(kv[0]), "-")
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		if flag.Lookup(name) == nil {
+			return errors.Errorf("%s:%d: unknown flag %q", path, n, name)
+		}
+		if err := flag.Set(name, value); err != nil {
+			return errors.Wrapf(err, "%s:%d: invalid value %q for %s", path, n, value, name)
+		}
+	}
+	return errors.Wrap(scanner.Err(), "couldn't read the config file")
+}
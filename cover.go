@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/otium/ytdl"
+	"github.com/pkg/errors"
+)
+
+// coverArtURL is the Cover Art Archive the front covers are fetched from.
+var coverArtURL = "https://coverartarchive.org"
+
+// fetchCovers disables all cover art if false.
+var fetchCovers = true
+
+type coverArt struct {
+	mime string
+	data []byte
+}
+
+// fileName returns the name the cover is saved as in the album folder.
+func (c *coverArt) fileName() string {
+	if c.mime == "image/png" {
+		return "cover.png"
+	}
+	return "cover.jpg"
+}
+
+// getImage downloads an image, nil is returned if the server doesn't have it.
+func getImage(what, url string) (*coverArt, error) {
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+	}
+
+	var cover *coverArt
+	err := netRetry.do(what, func() error {
+		resp, err := httpClient.Get(url)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		if resp.StatusCode != http.StatusOK {
+			return newStatusError(resp)
+		}
+
+		data, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return errors.Wrap(err, "reading response body failed")
+		}
+
+		mime := resp.Header.Get("Content-Type")
+		if i := strings.Index(mime, ";"); i >= 0 {
+			mime = mime[:i]
+		}
+		if !strings.HasPrefix(mime, "image/") {
+			mime = http.DetectContentType(data)
+		}
+		cover = &coverArt{mime: mime, data: data}
+		return nil
+	})
+	return cover, err
+}
+
+// getCover returns the front cover of a release. The cover of the release
+// group and the thumbnail of the video are used as fallbacks, also if one of
+// them fails. An error is only returned if all of them failed. vid may be nil.
+func getCover(release, group gomusicbrainz.MBID, vid *ytdl.VideoInfo) (*coverArt, error) {
+	var urls []string
+	if release != "" {
+		urls = append(urls, fmt.Sprintf("%s/release/%s/front", coverArtURL, release))
+	}
+	if group != "" {
+		urls = append(urls, fmt.Sprintf("%s/release-group/%s/front", coverArtURL, group))
+	}
+	if vid != nil {
+		urls = append(urls,
+			vid.GetThumbnailURL(ytdl.ThumbnailQualityMaxRes).String(),
+			vid.GetThumbnailURL(ytdl.ThumbnailQualityHigh).String())
+	}
+
+	var failed int
+	var lastErr error
+	for _, url := range urls {
+		cover, err := getImage("getting the cover", url)
+		if err != nil {
+			fmt.Printf("Warning: couldn't get the cover from %s: %v\n", url, err)
+			failed, lastErr = failed+1, err
+			continue
+		}
+		if cover != nil {
+			return cover, nil
+		}
+	}
+	if failed > 0 && failed == len(urls) {
+		return nil, errors.Wrap(lastErr, "every cover source failed")
+	}
+	return nil, nil
+}
+
+// loadCover fetches the cover of a release and saves it in the album folder.
+// Covers are optional, so failures are only reported.
+func loadCover(release, group gomusicbrainz.MBID, vid *ytdl.VideoInfo, dlFolder string) *coverArt {
+	if !fetchCovers {
+		return nil
+	}
+
+	cover, err := getCover(release, group, vid)
+	if err != nil {
+		fmt.Printf("Warning: couldn't get the cover: %v\n", err)
+		return nil
+	}
+	if cover == nil {
+		fmt.Println("Warning: couldn't find a cover")
+		return nil
+	}
+
+	os.MkdirAll(dlFolder, 0777)
+	if err := ioutil.WriteFile(filepath.Join(dlFolder, cover.fileName()), cover.data, 0666); err != nil {
+		fmt.Printf("Warning: couldn't save the cover: %v\n", err)
+	}
+	return cover
+}
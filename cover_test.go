@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n")
+
+// coverArchive is a stub of the Cover Art Archive. The archive redirects
+// covers to their image files.
+func coverArchive(release, group int) *httptest.Server {
+	mux := http.NewServeMux()
+	status := func(path string, code int) {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if code == http.StatusFound {
+				http.Redirect(w, r, "/images/front.png", code)
+				return
+			}
+			w.WriteHeader(code)
+		})
+	}
+	status("/release/r1/front", release)
+	status("/release-group/g1/front", group)
+	mux.HandleFunc("/images/front.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(pngHeader)
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestGetCover(t *testing.T) {
+	defer fastRetries()()
+	netRetry.attempts = 1
+	defer func(u string) { coverArtURL = u }(coverArtURL)
+
+	for _, tc := range []struct {
+		name           string
+		release, group int
+		found, fails   bool
+	}{
+		{"release", http.StatusFound, http.StatusNotFound, true, false},
+		{"release group after 404", http.StatusNotFound, http.StatusFound, true, false},
+		{"release group after 400", http.StatusBadRequest, http.StatusFound, true, false},
+		{"release group after 503", http.StatusServiceUnavailable, http.StatusFound, true, false},
+		{"no cover", http.StatusNotFound, http.StatusNotFound, false, false},
+		{"everything fails", http.StatusInternalServerError, http.StatusBadGateway, false, true},
+	} {
+		srv := coverArchive(tc.release, tc.group)
+		coverArtURL = srv.URL
+		cover, err := getCover("r1", "g1", nil)
+		srv.Close()
+
+		if (err != nil) != tc.fails {
+			t.Errorf("%s: err = %v", tc.name, err)
+		}
+		if (cover != nil) != tc.found {
+			t.Errorf("%s: cover = %v", tc.name, cover)
+			continue
+		}
+		if cover != nil && (cover.mime != "image/png" || !bytes.Equal(cover.data, pngHeader) || cover.fileName() != "cover.png") {
+			t.Errorf("%s: got a %s cover of %d bytes", tc.name, cover.mime, len(cover.data))
+		}
+	}
+}
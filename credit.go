@@ -0,0 +1,98 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/tidwall/gjson"
+)
+
+// artistCredit is how musicbrainz credits the artists of a release or track,
+// e.g. "Artist feat. Guest". gomusicbrainz drops the credited names and join
+// phrases, so credits are decoded separately.
+type artistCredit []nameCredit
+
+type nameCredit struct {
+	Name       string         `xml:"name"` // may differ from the name of the artist
+	JoinPhrase string         `xml:"joinphrase,attr"`
+	Artist     creditedArtist `xml:"artist"`
+}
+
+type creditedArtist struct {
+	ID       string `xml:"id,attr"`
+	Name     string `xml:"name"`
+	SortName string `xml:"sort-name"`
+}
+
+// jsonCredit returns the artist credit of a json release or recording.
+func jsonCredit(value gjson.Result) artistCredit {
+	var credit artistCredit
+	value.Get("artist-credit").ForEach(func(key, c gjson.Result) bool {
+		credit = append(credit, nameCredit{
+			Name:       c.Get("name").String(),
+			JoinPhrase: c.Get("joinphrase").String(),
+			Artist: creditedArtist{
+				ID:       c.Get("artist.id").String(),
+				Name:     c.Get("artist.name").String(),
+				SortName: c.Get("artist.sort-name").String(),
+			},
+		})
+		return true
+	})
+	return credit
+}
+
+func (c nameCredit) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Artist.Name
+}
+
+func (c nameCredit) sortName() string {
+	if c.Artist.SortName != "" {
+		return c.Artist.SortName
+	}
+	return c.name()
+}
+
+// join renders the credit with its join phrases.
+func (c artistCredit) join(name func(nameCredit) string) string {
+	var s string
+	for i, nc := range c {
+		s += name(nc)
+		switch {
+		case nc.JoinPhrase != "":
+			s += nc.JoinPhrase
+		case i < len(c)-1:
+			s += ", "
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+// String returns the credit as printed on the release, e.g. "A feat. B".
+func (c artistCredit) String() string {
+	return c.join(nameCredit.name)
+}
+
+// sortName returns the credit with sort names, e.g. "Beatles, The".
+func (c artistCredit) sortName() string {
+	return c.join(nameCredit.sortName)
+}
+
+// names returns the credited name of every artist.
+func (c artistCredit) names() []string {
+	names := make([]string, 0, len(c))
+	for _, nc := range c {
+		names = append(names, nc.name())
+	}
+	return names
+}
+
+func (c artistCredit) ids() []string {
+	ids := make([]string, 0, len(c))
+	for _, nc := range c {
+		ids = append(ids, nc.Artist.ID)
+	}
+	return ids
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// a cue sheet index is given in minutes, seconds and frames with 75 frames per
+// second
+const cueFramesPerSecond = 75
+
+type cueTrack struct {
+	title     string
+	performer string
+	start     float64
+}
+
+type cueSheet struct {
+	file      string
+	title     string
+	performer string
+	tracks    []cueTrack
+}
+
+// offsets returns the start of every track in seconds and the track titles.
+func (c cueSheet) offsets() ([]float64, []string) {
+	tracks := make([]float64, 0, len(c.tracks))
+	titles := make([]string, 0, len(c.tracks))
+	for _, t := range c.tracks {
+		tracks = append(tracks, t.start)
+		titles = append(titles, t.title)
+	}
+	return tracks, titles
+}
+
+// query returns a search query for the album of the cue sheet.
+func (c cueSheet) query() string {
+	if c.performer != "" && c.title != "" {
+		return c.performer + " - " + c.title
+	}
+	return fileQuery(c.file)
+}
+
+func cueIndexToSec(index string) (float64, error) {
+	data := strings.Split(index, ":")
+	if len(data) != 3 {
+		return 0, errInvalidInput
+	}
+
+	var v [3]int
+	for i := range data {
+		var err error
+		if v[i], err = strconv.Atoi(data[i]); err != nil {
+			return 0, errInvalidInput
+		}
+	}
+	return float64(v[0]*60+v[1]) + float64(v[2])/cueFramesPerSecond, nil
+}
+
+func secToCueIndex(sec float64) string {
+	frames := int(sec*cueFramesPerSecond + 0.5)
+	return fmt.Sprintf("%.2d:%.2d:%.2d", frames/(60*cueFramesPerSecond), frames/cueFramesPerSecond%60, frames%cueFramesPerSecond)
+}
+
+// cueFields splits a cue sheet line into its fields. Quoted fields may contain
+// spaces.
+func cueFields(line string) []string {
+	var fields []string
+	for line = strings.TrimSpace(line); line != ""; line = strings.TrimSpace(line) {
+		if line[0] == '"' {
+			end := strings.IndexByte(line[1:], '"')
+			if end < 0 {
+				fields = append(fields, line[1:])
+				break
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			fields = append(fields, line)
+			break
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+	return fields
+}
+
+// parseCue reads the tracks of a cue sheet. Only the INDEX 01 of every track is
+// used as cut point. The file of the sheet is resolved relative to the sheet.
+func parseCue(path string) (cueSheet, error) {
+	var sheet cueSheet
+
+	f, err := os.Open(path)
+	if err != nil {
+		return sheet, errors.Wrap(err, "couldn't open the cue sheet")
+	}
+	defer f.Close()
+
+	var track *cueTrack
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := cueFields(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if len(fields) < 2 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "FILE":
+			sheet.file = fields[1]
+			if !filepath.IsAbs(sheet.file) && !isURL(sheet.file) {
+				sheet.file = filepath.Join(filepath.Dir(path), sheet.file)
+			}
+		case "TRACK":
+			sheet.tracks = append(sheet.tracks, cueTrack{start: -1})
+			track = &sheet.tracks[len(sheet.tracks)-1]
+		case "TITLE":
+			if track != nil {
+				track.title = fields[1]
+			} else {
+				sheet.title = fields[1]
+			}
+		case "PERFORMER":
+			if track != nil {
+				track.performer = fields[1]
+			} else {
+				sheet.performer = fields[1]
+			}
+		case "INDEX":
+			if track != nil && len(fields) > 2 && fields[1] == "01" {
+				if track.start, err = cueIndexToSec(fields[2]); err != nil {
+					return sheet, errors.Wrapf(err, "invalid index in track %d", len(sheet.tracks))
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return sheet, errors.Wrap(err, "couldn't read the cue sheet")
+	}
+
+	for i, t := range sheet.tracks {
+		if t.start < 0 {
+			return sheet, errors.Errorf("track %d has no INDEX 01", i+1)
+		}
+	}
+	if len(sheet.tracks) == 0 {
+		return sheet, errNoTimestamps
+	}
+	return sheet, nil
+}
+
+func isURL(s string) bool {
+	return strings.Contains(s, "://")
+}
+
+// cueVideo returns the video url a cue sheet refers to if it was written for a
+// video that wasn't cached.
+func cueVideo(path string) (string, bool) {
+	if !strings.EqualFold(filepath.Ext(path), ".cue") {
+		return "", false
+	}
+	sheet, err := parseCue(path)
+	if err != nil || !isURL(sheet.file) {
+		return "", false
+	}
+	return sheet.file, true
+}
+
+func cueFileType(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".mp3":
+		return "MP3"
+	case ".aif", ".aiff":
+		return "AIFF"
+	default:
+		return "WAVE"
+	}
+}
+
+// cueQuote makes s safe to be used in a quoted cue sheet field.
+func cueQuote(s string) string {
+	return `"` + strings.Replace(s, `"`, "'", -1) + `"`
+}
+
+// writeCue writes a cue sheet that records how sourceFile was split into the
+// tracks of mbr. If videoID is set sourceFile is a downloaded video, the sheet
+// refers to it if it stays in the cache and to the video url otherwise.
+func writeCue(path, sourceFile, videoID string, tracks []float64, mbr musicBrainzRelease) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "couldn't create the cue sheet")
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	if mbr.date != "" {
+		fmt.Fprintf(w, "REM DATE %s\n", dateYear(mbr.date))
+	}
+	fmt.Fprintf(w, "PERFORMER %s\n", cueQuote(mbr.albumCredit.String()))
+	fmt.Fprintf(w, "TITLE %s\n", cueQuote(mbr.title))
+	if videoID != "" {
+		fmt.Fprintf(w, "REM YOUTUBE %s\n", videoID)
+	}
+	file := sourceFile
+	if videoID != "" && !videoCache.enabled() {
+		file = watchURL(videoID)
+	} else {
+		abs, err := filepath.Abs(sourceFile)
+		if err != nil {
+			return errors.Wrap(err, "couldn't resolve the source file")
+		}
+		if file, err = filepath.Rel(filepath.Dir(path), abs); err != nil {
+			file = abs
+		}
+	}
+	fmt.Fprintf(w, "FILE %s %s\n", cueQuote(file), cueFileType(sourceFile))
+
+	for i := 0; i < len(tracks) && i < len(mbr.tracks); i++ {
+		rec := mbr.tracks[i].Recording
+		fmt.Fprintf(w, "  TRACK %.2d AUDIO\n", i+1)
+		fmt.Fprintf(w, "    TITLE %s\n", cueQuote(rec.Title))
+		fmt.Fprintf(w, "    PERFORMER %s\n", cueQuote(mbr.recording(i).trackCredit.String()))
+		fmt.Fprintf(w, "    INDEX 01 %s\n", secToCueIndex(tracks[i]))
+	}
+
+	return errors.Wrap(w.Flush(), "couldn't write the cue sheet")
+}
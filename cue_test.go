@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCueIndex(t *testing.T) {
+	for index, sec := range map[string]float64{
+		"00:00:00": 0,
+		"03:45:00": 225,
+		"62:03:60": 3723.8,
+		"01:00:01": 60 + 1.0/75,
+	} {
+		got, err := cueIndexToSec(index)
+		if err != nil || got != sec {
+			t.Errorf("cueIndexToSec(%q) = %v, %v, want %v", index, got, err, sec)
+		}
+		if back := secToCueIndex(sec); back != index {
+			t.Errorf("secToCueIndex(%v) = %q, want %q", sec, back, index)
+		}
+	}
+	for _, index := range []string{"", "3:45", "00:xx:00", "1:2:3:4"} {
+		if _, err := cueIndexToSec(index); err == nil {
+			t.Errorf("cueIndexToSec(%q) succeeded", index)
+		}
+	}
+}
+
+func cueRelease() musicBrainzRelease {
+	credit := manualCredit("Artist")
+	return plainRelease(releaseTags{albumCredit: credit, date: "1999-03-01"}, `Album "Deluxe"`, []plainTrack{
+		{title: "First", credit: credit},
+		{title: "Second", credit: manualCredit("Artist feat. Guest")},
+		{title: "Third", credit: credit},
+	})
+}
+
+func TestCueRoundTrip(t *testing.T) {
+	defer func(c downloadCache) { videoCache = c }(videoCache)
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	source := filepath.Join(dir, "source", "album.flac")
+	if err := os.MkdirAll(filepath.Dir(source), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(source, nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	cached := filepath.Join(dir, "cache", "aaaaaaaaaaa", "140")
+
+	for _, tc := range []struct {
+		name     string
+		source   string
+		videoID  string
+		cache    string
+		wantFile string
+	}{
+		{"local file", source, "", "", source},
+		{"cached video", cached, "aaaaaaaaaaa", filepath.Join(dir, "cache"), cached},
+		{"uncached video", filepath.Join(dir, "album", "Album"), "aaaaaaaaaaa", "", watchURL("aaaaaaaaaaa")},
+	} {
+		videoCache = downloadCache{dir: tc.cache}
+		path := filepath.Join(dir, "album", tc.name+".cue")
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatal(err)
+		}
+
+		offsets := []float64{0, 225.5, 3723}
+		if err := writeCue(path, tc.source, tc.videoID, offsets, cueRelease()); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		sheet, err := parseCue(path)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+
+		// the offsets are rounded to frames
+		tracks, titles := sheet.offsets()
+		if len(tracks) != len(offsets) || !reflect.DeepEqual(titles, []string{"First", "Second", "Third"}) {
+			t.Fatalf("%s: read %v %q", tc.name, tracks, titles)
+		}
+		for i := range tracks {
+			if math.Abs(tracks[i]-offsets[i]) > 1.0/cueFramesPerSecond {
+				t.Errorf("%s: track %d starts at %v, want %v", tc.name, i+1, tracks[i], offsets[i])
+			}
+		}
+		if sheet.file != tc.wantFile {
+			t.Errorf("%s: file = %q, want %q", tc.name, sheet.file, tc.wantFile)
+		}
+		if sheet.query() != `Artist - Album 'Deluxe'` {
+			t.Errorf("%s: query = %q", tc.name, sheet.query())
+		}
+		if sheet.tracks[1].performer != "Artist feat. Guest" {
+			t.Errorf("%s: the second track is performed by %q", tc.name, sheet.tracks[1].performer)
+		}
+
+		video, ok := cueVideo(path)
+		if want := tc.wantFile == watchURL("aaaaaaaaaaa"); ok != want || ok && video != tc.wantFile {
+			t.Errorf("%s: cueVideo = %q, %v", tc.name, video, ok)
+		}
+	}
+}
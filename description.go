@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	timestampRe   = regexp.MustCompile(`[\[(]?\b(\d{1,2}(?::\d{1,2}){1,2})\b[\])]?`)
+	trackNumberRe = regexp.MustCompile(`^(?:\d{1,3}[.)]|\d{1,3}\s+[-–—]\s)\s*`)
+)
+
+const titleSeparators = " \t-–—|:.,;•·*"
+
+// parseTimestamps finds the tracklist in a video description. Every line that
+// contains a timestamp becomes a track, the rest of the line is its title.
+// Lines like "00:00 Intro", "1. Song (3:45)", "[1:02:03] Title" and
+// "Title - 12:34" are understood. Timestamps that don't increase are track
+// lengths, increasing ones are offsets unless they add up to length, the
+// length of the video if it is known, see isLengths.
+func parseTimestamps(text string, length time.Duration) ([]float64, []string) {
+	var tracks []float64
+	var titles []string
+
+	for _, line := range strings.Split(text, "\n") {
+		loc := timestampRe.FindStringSubmatchIndex(line)
+		if loc == nil {
+			continue
+		}
+
+		sec, err := durToSec(line[loc[2]:loc[3]])
+		if err != nil {
+			continue
+		}
+
+		title := strings.TrimSpace(line[:loc[0]] + " " + line[loc[1]:])
+		title = strings.Trim(title, titleSeparators)
+		title = trackNumberRe.ReplaceAllString(title, "")
+		title = strings.Trim(title, titleSeparators)
+
+		tracks = append(tracks, float64(sec))
+		titles = append(titles, title)
+	}
+
+	if len(tracks) < 2 {
+		return nil, nil
+	}
+	if isLengths(tracks, length.Seconds()) {
+		return lengthsToOffsets(tracks), titles
+	}
+	return tracks, titles
+}
+
+// isLengths reports whether the timestamps of a tracklist are track lengths
+// instead of offsets. total is the length of the video, 0 if unknown. Lengths
+// add up to the video, offsets end an average track before its end.
+func isLengths(tracks []float64, total float64) bool {
+	var sum float64
+	for i, t := range tracks {
+		if i > 0 && t <= tracks[i-1] {
+			return true
+		}
+		sum += t
+	}
+	// no track is 0:00 long
+	if tracks[0] == 0 || total <= 0 {
+		return false
+	}
+
+	last := tracks[len(tracks)-1]
+	offsetsEnd := last + last/float64(len(tracks)-1)
+	return last >= total || math.Abs(sum-total) < math.Abs(offsetsEnd-total)
+}
+
+func lengthsToOffsets(lengths []float64) []float64 {
+	offsets := make([]float64, len(lengths))
+	for i := 1; i < len(lengths); i++ {
+		offsets[i] = offsets[i-1] + lengths[i-1]
+	}
+	return offsets
+}
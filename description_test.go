@@ -0,0 +1,86 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamps(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		text   string
+		length time.Duration // of the video, 0 if unknown
+		tracks []float64
+		titles []string
+	}{
+		{
+			"offsets first",
+			"Tracklist:\n00:00 Intro\n03:45 Song B\n1:02:03 Song C\n\nThanks for listening",
+			0,
+			[]float64{0, 225, 3723},
+			[]string{"Intro", "Song B", "Song C"},
+		},
+		{
+			"offsets that don't start at 0:00",
+			"0:05 Intro\n3:00 Song B\n6:00 Song C",
+			9 * time.Minute,
+			[]float64{5, 180, 360},
+			[]string{"Intro", "Song B", "Song C"},
+		},
+		{
+			"numbered offsets",
+			"1. Intro (0:00)\n2. Song B (3:10)\n3. Song C (7:00)",
+			10 * time.Minute,
+			[]float64{0, 190, 420},
+			[]string{"Intro", "Song B", "Song C"},
+		},
+		{
+			"numbered lengths that add up to the video",
+			"1. Song A (3:45)\n2. Song B (4:10)\n3. Song C (5:00)",
+			12*time.Minute + 55*time.Second,
+			[]float64{0, 225, 475},
+			[]string{"Song A", "Song B", "Song C"},
+		},
+		{
+			"bracketed offsets",
+			"[0:00] Song A\n[3:45] Song B\n[1:02:03] Song C",
+			70 * time.Minute,
+			[]float64{0, 225, 3723},
+			[]string{"Song A", "Song B", "Song C"},
+		},
+		{
+			"offsets after the title",
+			"Song A - 0:00\nSong B - 3:45\nSong C | 12:34",
+			0,
+			[]float64{0, 225, 754},
+			[]string{"Song A", "Song B", "Song C"},
+		},
+		{
+			"lengths after the title",
+			"Song A - 3:45\nSong B - 4:10\nSong C - 5:00",
+			13 * time.Minute,
+			[]float64{0, 225, 475},
+			[]string{"Song A", "Song B", "Song C"},
+		},
+		{
+			"lengths that decrease",
+			"Song A 4:10\nSong B 3:00\nSong C 3:30",
+			0,
+			[]float64{0, 250, 430},
+			[]string{"Song A", "Song B", "Song C"},
+		},
+		{
+			"single timestamp",
+			"Released 2019, recorded in 3:45 hours",
+			0,
+			nil,
+			nil,
+		},
+	} {
+		tracks, titles := parseTimestamps(tc.text, tc.length)
+		if !reflect.DeepEqual(tracks, tc.tracks) || !reflect.DeepEqual(titles, tc.titles) {
+			t.Errorf("%s: got %v %q, want %v %q", tc.name, tracks, titles, tc.tracks, tc.titles)
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// discogs looks up releases with the discogs api. Searching needs a personal
+// access token.
+type discogs struct {
+	baseURL   string
+	token     string
+	userAgent string
+	client    *http.Client
+	limiter   *tokenBucket
+	cache     responseCache
+}
+
+func newDiscogs(baseURL, token, contact string, cache responseCache) *discogs {
+	return &discogs{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		token:     token,
+		userAgent: fmt.Sprintf("%s/%s +%s", appName, version, contact),
+		client:    &http.Client{Timeout: 30 * time.Second},
+		// authenticated clients may send 60 requests per minute
+		limiter: newTokenBucket(1, 1),
+		cache:   cache,
+	}
+}
+
+func (d *discogs) name() string {
+	return "discogs"
+}
+
+func (d *discogs) get(what, endpoint string, params url.Values) (gjson.Result, error) {
+	if d.token == "" {
+		return gjson.Result{}, errors.New("discogs needs a token, see -discogs-token")
+	}
+	header := http.Header{
+		"User-Agent":    {d.userAgent},
+		"Authorization": {"Discogs token=" + d.token},
+	}
+	data, err := fetch(d.client, d.limiter, d.cache, what, d.baseURL+endpoint, params, header)
+	if err != nil {
+		return gjson.Result{}, err
+	}
+	return gjson.ParseBytes(data), nil
+}
+
+// search returns the discogs releases that match the parameters.
+func (d *discogs) search(params url.Values) ([]gjson.Result, error) {
+	params.Set("type", "release")
+	params.Set("per_page", "5")
+	result, err := d.get("searching on discogs", "/database/search", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var releases []gjson.Result
+	for _, r := range result.Get("results").Array() {
+		release, err := d.get("looking up the release", "/releases/"+r.Get("id").String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releases, nil
+}
+
+func (d *discogs) findRelease(query string, hints releaseHints) (musicBrainzRelease, error) {
+	artist, title := queryTitle(query, hints.author, hints.provided, true)
+	fmt.Println("\nSearching release on discogs: ")
+	found, err := d.search(url.Values{"artist": {artist}, "release_title": {title}})
+	if err != nil {
+		return musicBrainzRelease{}, err
+	}
+
+	var releases []musicBrainzRelease
+	var masters []string // the master release of every candidate
+	var candidates []candidate
+	for _, release := range found {
+		r := discogsRelease(release)
+		score := int(100 * (titleSimilarity(title, r.title) + titleSimilarity(artist, r.albumCredit.String())) / 2)
+		rs, cs := plainMedia(d.name(), r, score, hints)
+		for range rs {
+			masters = append(masters, discogsMaster(release))
+		}
+		releases = append(releases, rs...)
+		candidates = append(candidates, cs...)
+	}
+
+	if i, ok := releaseMatcher.choose("Choose release?", candidates); ok {
+		return d.withOriginalDate(releases[i], masters[i]), nil
+	}
+	return musicBrainzRelease{}, errNoRelease
+}
+
+func (d *discogs) findTrack(query string, hints trackHints) (musicBrainzRecording, error) {
+	artist, title := queryTitle(query, hints.author, hints.provided, false)
+	hints.title = title
+	fmt.Println("\nSearching track on discogs: ")
+	found, err := d.search(url.Values{"artist": {artist}, "track": {title}})
+	if err != nil {
+		return musicBrainzRecording{}, err
+	}
+
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	for _, release := range found {
+		r := discogsRelease(release)
+		score := int(100 * titleSimilarity(artist, r.albumCredit.String()))
+		rs, cs := plainTracks(d.name(), r, score, hints)
+		recordings = append(recordings, rs...)
+		candidates = append(candidates, cs...)
+	}
+
+	if i, ok := releaseMatcher.choose("Choose track?", candidates); ok {
+		return recordings[i], nil
+	}
+	return musicBrainzRecording{}, errNoRelease
+}
+
+// withOriginalDate adds the year of the master release, it is only looked up
+// for the chosen release.
+func (d *discogs) withOriginalDate(r musicBrainzRelease, masterID string) musicBrainzRelease {
+	if masterID == "" {
+		return r
+	}
+	master, err := d.get("looking up the master release", "/masters/"+masterID, nil)
+	if err != nil {
+		fmt.Printf("Warning: couldn't get the original release date: %v\n", err)
+		return r
+	}
+	if year := master.Get("year").Int(); year > 0 {
+		r.originalDate = strconv.FormatInt(year, 10)
+	}
+	return r
+}
+
+var (
+	// discogs numbers artists and labels with the same name, e.g. "Nirvana (2)"
+	discogsNumberRe = regexp.MustCompile(`\s+\(\d+\)$`)
+	// positions like "2-05" or "CD2-5" contain the disc, "A1" doesn't
+	discogsDiscRe = regexp.MustCompile(`^(?:CD|DVD)?(\d+)[-.]\d+$`)
+)
+
+// discogsMaster returns the id of the master release a discogs release belongs
+// to, or an empty string if it has none.
+func discogsMaster(release gjson.Result) string {
+	if id := release.Get("master_id").String(); id != "0" {
+		return id
+	}
+	return ""
+}
+
+func discogsName(name string) string {
+	return discogsNumberRe.ReplaceAllString(name, "")
+}
+
+// discogsCredit returns the artist credit of a discogs release or track.
+func discogsCredit(artists gjson.Result) artistCredit {
+	var credit artistCredit
+	artists.ForEach(func(key, a gjson.Result) bool {
+		nc := nameCredit{
+			Name:   a.Get("anv").String(),
+			Artist: creditedArtist{Name: discogsName(a.Get("name").String())},
+		}
+		switch join := strings.TrimSpace(a.Get("join").String()); join {
+		case "":
+		case ",":
+			nc.JoinPhrase = ", "
+		default:
+			nc.JoinPhrase = " " + join + " "
+		}
+		credit = append(credit, nc)
+		return true
+	})
+	return credit
+}
+
+// discogsRelease turns a discogs release into the common model.
+func discogsRelease(release gjson.Result) musicBrainzRelease {
+	tags := releaseTags{
+		albumCredit:   discogsCredit(release.Get("artists")),
+		date:          strings.TrimSuffix(strings.TrimSuffix(release.Get("released").String(), "-00"), "-00"),
+		country:       release.Get("country").String(),
+		label:         discogsName(release.Get("labels.0.name").String()),
+		catalogNumber: release.Get("labels.0.catno").String(),
+	}
+	if tags.date == "" && release.Get("year").Int() > 0 {
+		tags.date = release.Get("year").String()
+	}
+	release.Get("identifiers").ForEach(func(key, id gjson.Result) bool {
+		if id.Get("type").String() == "Barcode" {
+			tags.barcode = id.Get("value").String()
+			return false
+		}
+		return true
+	})
+
+	votes := make(map[string]int)
+	for _, g := range append(release.Get("genres").Array(), release.Get("styles").Array()...) {
+		if name := strings.ToLower(g.String()); genreTags.allowed(name, true) {
+			votes[name]++
+		}
+	}
+	tags.genres = genreTags.top(votes)
+
+	var tracks []plainTrack
+	release.Get("tracklist").ForEach(func(key, t gjson.Result) bool {
+		// headings and the like aren't tracks
+		if typ := t.Get("type_").String(); typ != "track" && typ != "index" {
+			return true
+		}
+
+		track := plainTrack{title: t.Get("title").String(), credit: discogsCredit(t.Get("artists"))}
+		if len(track.credit) == 0 {
+			track.credit = tags.albumCredit
+		}
+		if s, err := durToSec(t.Get("duration").String()); err == nil {
+			track.length = time.Duration(s) * time.Second
+		}
+		if m := discogsDiscRe.FindStringSubmatch(t.Get("position").String()); m != nil {
+			track.disc, _ = strconv.Atoi(m[1])
+		}
+		tracks = append(tracks, track)
+		return true
+	})
+	return plainRelease(tags, release.Get("title").String(), tracks)
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const discogsReleaseJSON = `{
+	"id": 1, "title": "Album", "released": "1999-03-00", "country": "US", "master_id": 10,
+	"artists": [{"name": "Artist (2)", "anv": "", "join": "&"}, {"name": "Guest", "anv": "", "join": ""}],
+	"labels": [{"name": "Label (3)", "catno": "CAT 1"}],
+	"identifiers": [{"type": "Matrix", "value": "x"}, {"type": "Barcode", "value": "0123"}],
+	"tracklist": [
+		{"type_": "heading", "title": "Disc One", "position": ""},
+		{"type_": "track", "title": "First", "position": "1-1", "duration": "3:00"},
+		{"type_": "track", "title": "Second", "position": "1-2", "duration": "4:00",
+			"artists": [{"name": "Other", "join": ""}]},
+		{"type_": "track", "title": "Third", "position": "2-1", "duration": "5:00"}
+	]
+}`
+
+// discogsServer is a stub of the discogs api that serves a single release.
+func discogsServer(t *testing.T, requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path)
+		if r.Header.Get("Authorization") != "Discogs token=secret" {
+			t.Errorf("%s was requested without the token", r.URL.Path)
+		}
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), appName+"/") {
+			t.Errorf("%s was requested without a user agent", r.URL.Path)
+		}
+
+		switch r.URL.Path {
+		case "/database/search":
+			if r.URL.Query().Get("artist") != "Artist" {
+				t.Errorf("searched for %q", r.URL.RawQuery)
+			}
+			fmt.Fprint(w, `{"results": [{"id": 1}]}`)
+		case "/releases/1":
+			fmt.Fprint(w, discogsReleaseJSON)
+		case "/masters/10":
+			fmt.Fprint(w, `{"id": 10, "year": 1971}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestDiscogsFindRelease(t *testing.T) {
+	defer func(m matcher, whole bool) { releaseMatcher, wholeRelease = m, whole }(releaseMatcher, wholeRelease)
+	releaseMatcher = matcher{mode: matchYes, threshold: 0.5}
+	wholeRelease = true
+
+	var requests []string
+	srv := discogsServer(t, &requests)
+	defer srv.Close()
+
+	d := newDiscogs(srv.URL, "secret", "test", responseCache{})
+	d.limiter = newTokenBucket(1000, 1)
+	r, err := d.findRelease("Artist - Album", releaseHints{trackCount: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"/database/search", "/releases/1", "/masters/10"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+	if r.title != "Album" || r.albumCredit.String() != "Artist & Guest" {
+		t.Errorf("release = %q by %q", r.title, r.albumCredit)
+	}
+	if r.date != "1999-03" || r.originalDate != "1971" {
+		t.Errorf("date = %q, original date = %q", r.date, r.originalDate)
+	}
+	if r.label != "Label" || r.catalogNumber != "CAT 1" || r.barcode != "0123" {
+		t.Errorf("label = %q, catalog number = %q, barcode = %q", r.label, r.catalogNumber, r.barcode)
+	}
+
+	var titles []string
+	for _, track := range r.tracks {
+		titles = append(titles, track.Recording.Title)
+	}
+	if want := []string{"First", "Second", "Third"}; !reflect.DeepEqual(titles, want) {
+		t.Errorf("tracks = %v, want %v", titles, want)
+	}
+	if !reflect.DeepEqual(r.discs, []int{1, 1, 2}) || r.discCount != 2 {
+		t.Errorf("discs = %v of %d", r.discs, r.discCount)
+	}
+	if credit := r.recording(1).trackCredit.String(); credit != "Other" {
+		t.Errorf("the second track is credited to %q", credit)
+	}
+}
+
+func TestDiscogsFindTrack(t *testing.T) {
+	defer func(m matcher) { releaseMatcher = m }(releaseMatcher)
+	releaseMatcher = matcher{mode: matchYes, threshold: 0.5}
+
+	var requests []string
+	srv := discogsServer(t, &requests)
+	defer srv.Close()
+
+	d := newDiscogs(srv.URL, "secret", "test", responseCache{})
+	d.limiter = newTokenBucket(1000, 1)
+	rec, err := d.findTrack("Artist - Third", trackHints{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.trackTitle != "Third" || rec.cdNum != 2 || rec.trackNum != 1 || rec.trackCount != 1 {
+		t.Errorf("got %q, track %d/%d of disc %d", rec.trackTitle, rec.trackNum, rec.trackCount, rec.cdNum)
+	}
+	// the master is only looked up for releases
+	if want := []string{"/database/search", "/releases/1"}; !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
+
+func TestDiscogsNeedsToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s was requested without a token", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	d := newDiscogs(srv.URL, "", "test", responseCache{})
+	if _, err := d.findRelease("Artist - Album", releaseHints{}); err == nil || !strings.Contains(err.Error(), "-discogs-token") {
+		t.Errorf("findRelease without a token: %v", err)
+	}
+	if _, err := d.findTrack("Artist - Song", trackHints{}); err == nil || !strings.Contains(err.Error(), "-discogs-token") {
+		t.Errorf("findTrack without a token: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
@@ -9,12 +11,48 @@ import (
 
 	"github.com/bogem/id3v2"
 	"github.com/cheggaaa/pb"
+	"github.com/otium/ytdl"
 	"github.com/pkg/errors"
 	"github.com/subosito/norma"
 )
 
 var errInvalidInput = errors.New("invalid duration")
 
+// discFolders puts the tracks of releases with more than one medium into a
+// "Disc N" folder per medium.
+var discFolders bool
+
+// folderYear is the date whose year is appended to album folders, "release",
+// "original" or "none".
+var folderYear = "none"
+
+// parseFolderYear checks the value of the -folder-year flag.
+func parseFolderYear(s string) (string, error) {
+	switch s = strings.ToLower(s); s {
+	case "none", "release", "original":
+		return s, nil
+	}
+	return "", errors.Errorf("unknown folder year %q", s)
+}
+
+// albumFolder returns the folder the tracks of a release are saved in.
+func albumFolder(library, title string, tags releaseTags) string {
+	var date string
+	switch folderYear {
+	case "release":
+		date = tags.date
+	case "original":
+		date = tags.originalDate
+		if date == "" {
+			date = tags.date
+		}
+	}
+	if year := dateYear(date); year != "" {
+		title += " (" + year + ")"
+	}
+	return filepath.Join(library, norma.Sanitize(tags.albumCredit.String()), norma.Sanitize(title))
+}
+
 func getLength(inputFile string) (int, error) {
 	re := regexp.MustCompile("Duration: [0-9]+:[0-9]+:[0-9]+")
 
@@ -35,27 +73,110 @@ func transcode(inputFile, outputFile string, start, length float64) error {
 	return cmd.Run()
 }
 
+func recordPath(mbr musicBrainzRecording, dlFolder string) string {
+	trackName := fmt.Sprintf("%.2d %s - %s", mbr.trackNum, mbr.trackCredit, mbr.trackTitle)
+	if mbr.discCount > 1 {
+		if discFolders {
+			dlFolder = filepath.Join(dlFolder, fmt.Sprintf("Disc %d", mbr.cdNum))
+		} else {
+			trackName = fmt.Sprintf("%d-%s", mbr.cdNum, trackName)
+		}
+	}
+	return filepath.Join(dlFolder, norma.Sanitize(trackName)) + "." + "mp3"
+}
+
+// cuePath returns the path of the cue sheet of mbr. The media of a release
+// may come from different videos, so each gets its own sheet.
+func cuePath(mbr musicBrainzRelease, dlFolder string) string {
+	name := mbr.title
+	if len(mbr.discs) > 0 && mbr.discs[0] == mbr.discs[len(mbr.discs)-1] {
+		name = fmt.Sprintf("%s (Disc %d)", name, mbr.discs[0])
+	}
+	return filepath.Join(dlFolder, norma.Sanitize(name)) + ".cue"
+}
+
+func tagRecord(path string, mbr musicBrainzRecording) error {
+	err := tagFile(path, mbr)
+	if err != nil {
+		return errors.Wrap(err, "tagFile failed")
+	}
+	return nil
+}
+
 func convertTrack(inputFile string, mbr musicBrainzRecording, dlFolder string) error {
 	l, err := getLength(inputFile)
 	if err != nil {
 		return errors.Wrap(err, "getLength failed")
 	}
-	artists := strings.Join(mbr.trackArtists, ",")
-	trackName := fmt.Sprintf("%.2d %s - %s", mbr.trackNum, artists, mbr.trackTitle)
-	trackFullPath := filepath.Join(dlFolder, norma.Sanitize(trackName)) + "." + "mp3"
+	trackFullPath := recordPath(mbr, dlFolder)
+	os.MkdirAll(filepath.Dir(trackFullPath), 0777)
 
 	if err := transcode(inputFile, trackFullPath, 0.0, float64(l)); err != nil {
 		return errors.Wrap(err, "transcode failed")
 	}
 
-	err = tagFile(trackFullPath, mbr.trackArtists, mbr.trackTitle, mbr.year, mbr.albumTitle, int(mbr.trackNum), int(mbr.trackCount), mbr.cdNum)
+	return tagRecord(trackFullPath, mbr)
+}
+
+// streamTrack transcodes a video while it is downloaded. The download is piped
+// into ffmpeg, so the video never touches the disk.
+func streamTrack(vid *ytdl.VideoInfo, format ytdl.Format, mbr musicBrainzRecording, dlFolder string) error {
+	trackFullPath := recordPath(mbr, dlFolder)
+	if err := os.MkdirAll(filepath.Dir(trackFullPath), 0777); err != nil {
+		return errors.Wrap(err, "couldn't create the album folder")
+	}
+
+	cmd := exec.Command("ffmpeg", "-y", "-i", "pipe:0",
+		"-codec:a", "libmp3lame", "-qscale:a", "3",
+		trackFullPath)
+	stdin, err := cmd.StdinPipe()
 	if err != nil {
-		return errors.Wrap(err, "tagFile failed")
+		return errors.Wrap(err, "couldn't open the ffmpeg stdin")
 	}
-	return nil
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "couldn't start ffmpeg")
+	}
+
+	// fill the cache on the way
+	var w io.Writer = stdin
+	var cacheFile *os.File
+	if videoCache.enabled() {
+		cachePath := videoCache.path(vid.ID, format.Itag)
+		os.MkdirAll(filepath.Dir(cachePath), 0777)
+		if cacheFile, err = os.Create(cachePath + ".part"); err == nil {
+			w = io.MultiWriter(stdin, cacheFile)
+		}
+	}
+
+	dlErr := downloadStream(vid, format, w)
+	stdin.Close()
+	err = cmd.Wait()
+
+	if cacheFile != nil {
+		cacheFile.Close()
+		if dlErr == nil {
+			cachePath := videoCache.path(vid.ID, format.Itag)
+			os.Rename(cacheFile.Name(), cachePath)
+			videoCache.evict(cachePath)
+		} else {
+			os.Remove(cacheFile.Name())
+		}
+	}
+
+	// the download fails too if ffmpeg exits early, ffmpeg's error tells why
+	if err != nil {
+		os.Remove(trackFullPath)
+		return errors.Wrap(err, "transcode failed")
+	}
+	if dlErr != nil {
+		os.Remove(trackFullPath)
+		return dlErr
+	}
+
+	return tagRecord(trackFullPath, mbr)
 }
 
-func extractTracks(inputFile string, tracks []float64, mbr musicBrainzRelease, dlFolder string) error {
+func extractTracks(inputFile, videoID string, tracks []float64, mbr musicBrainzRelease, dlFolder string) error {
 	l, err := getLength(inputFile)
 	if err != nil {
 		return errors.Wrap(err, "getLength failed")
@@ -68,12 +189,9 @@ func extractTracks(inputFile string, tracks []float64, mbr musicBrainzRelease, d
 
 	for i := 0; i < len(tracks)-1; i++ {
 		if len(mbr.tracks) > i {
-
-			artist := getArtists(mbr.tracks[i].Recording.ArtistCredit.NameCredits)
-			title := mbr.tracks[i].Recording.Title
-
-			trackName := fmt.Sprintf("%.2d %s - %s", i+1, strings.Join(artist, ","), title)
-			trackFullPath := filepath.Join(dlFolder, norma.Sanitize(trackName)) + "." + "mp3"
+			rec := mbr.recording(i)
+			trackFullPath := recordPath(rec, dlFolder)
+			os.MkdirAll(filepath.Dir(trackFullPath), 0777)
 			start := tracks[i]
 			end := tracks[i+1]
 			length := end - start
@@ -82,47 +200,127 @@ func extractTracks(inputFile string, tracks []float64, mbr musicBrainzRelease, d
 				return errors.Wrap(err, "transcode failed")
 			}
 
-			err = tagFile(trackFullPath, artist, title, mbr.year, mbr.title, i+1, len(mbr.tracks), -1)
-			if err != nil {
-				return errors.Wrap(err, "tagFile failed")
+			if err := tagRecord(trackFullPath, rec); err != nil {
+				return err
 			}
 
 			bar.Increment()
 		}
 	}
-	return nil
+
+	return writeCue(cuePath(mbr, dlFolder), inputFile, videoID, tracks[:len(tracks)-1], mbr)
 }
 
-func tagFile(path string, artists []string, title, year, album string, trackNum, tracksTotal int, cdNum int64) error {
+func tagFile(path string, mbr musicBrainzRecording) error {
 	tag, err := id3v2.Open(path, id3v2.Options{Parse: true})
 	if err != nil {
 		return errors.Wrap(err, "id3v2 open failed")
 	}
 	defer tag.Close()
 
-	tag.SetArtist(strings.Join(artists, "\x00"))
-	tag.SetTitle(title)
-	tag.SetYear(year)
-	tag.SetAlbum(album)
+	tag.SetArtist(mbr.trackCredit.String())
+	tag.SetTitle(mbr.trackTitle)
+	tag.SetAlbum(mbr.albumTitle)
+
+	text := func(id, value string) {
+		if value != "" {
+			tag.AddFrame(tag.CommonID(id), id3v2.TextFrame{Encoding: id3v2.ENUTF8, Text: value})
+		}
+	}
+	// id3v2.3 has no multiple values, players split them on slashes
+	sep := "\x00"
+	if tag.Version() < 4 {
+		sep = "/"
+	}
+	text("TPE2", mbr.albumCredit.String())
+	text("TSOP", mbr.trackCredit.sortName())
+	text("TSO2", mbr.albumCredit.sortName())
+	if tag.Version() < 4 {
+		// id3v2.3 has no full dates
+		text("TYER", dateYear(mbr.date))
+		text("TORY", dateYear(mbr.originalDate))
+	} else {
+		text("TDRC", mbr.date)
+		text("TDOR", mbr.originalDate)
+	}
 
 	trckFrame := id3v2.TextFrame{
 		Encoding: id3v2.ENUTF8,
-		Text:     fmt.Sprintf("%d/%d", trackNum, tracksTotal),
+		Text:     fmt.Sprintf("%d/%d", mbr.trackNum, mbr.trackCount),
 	}
 	tag.AddFrame(tag.CommonID("TRCK"), trckFrame)
 
-	if cdNum >= 0 {
+	if mbr.cdNum >= 0 {
+		tpos := fmt.Sprintf("%d", mbr.cdNum)
+		if mbr.discCount > 0 {
+			tpos = fmt.Sprintf("%d/%d", mbr.cdNum, mbr.discCount)
+		}
 		tposFrame := id3v2.TextFrame{
 			Encoding: id3v2.ENUTF8,
-			Text:     fmt.Sprintf("%d", cdNum),
+			Text:     tpos,
 		}
 		tag.AddFrame(tag.CommonID("TPOS"), tposFrame)
 	}
 
+	if mbr.cover != nil {
+		tag.AddAttachedPicture(id3v2.PictureFrame{
+			Encoding:    id3v2.ENUTF8,
+			MimeType:    mbr.cover.mime,
+			PictureType: id3v2.PTFrontCover,
+			Description: "Front cover",
+			Picture:     mbr.cover.data,
+		})
+	}
+
+	text("TCON", strings.Join(mbr.genres, sep))
+	text("TPUB", mbr.label)
+
+	text("TIT1", mbr.work.work)
+	text("TCOM", strings.Join(mbr.work.composers, sep))
+	text("TEXT", strings.Join(mbr.work.lyricists, sep))
+	if mbr.work.movement != "" {
+		text("MVNM", mbr.work.movement)
+		if mbr.work.movementNumber > 0 {
+			text("MVIN", fmt.Sprint(mbr.work.movementNumber))
+		}
+	}
+
 	// Write it to file.
 	if err = tag.Save(); err != nil {
 		return errors.Wrap(err, "tag save failed")
 	}
 
-	return nil
+	return addRawFrames(path, musicBrainzFrames(mbr))
+}
+
+// musicBrainzFrames returns the frames Picard writes to link a file to
+// musicbrainz. Unknown values are left out.
+func musicBrainzFrames(mbr musicBrainzRecording) []rawFrame {
+	var frames []rawFrame
+	if mbr.recordingID != "" {
+		frames = append(frames, ufidFrame("http://musicbrainz.org", string(mbr.recordingID)))
+	}
+
+	txxx := func(desc string, values ...string) {
+		for _, v := range values {
+			if v != "" {
+				frames = append(frames, txxxFrame(desc, values...))
+				return
+			}
+		}
+	}
+	txxx("MusicBrainz Album Id", string(mbr.releaseID))
+	txxx("MusicBrainz Release Group Id", string(mbr.groupID))
+	txxx("MusicBrainz Release Track Id", string(mbr.trackID))
+	txxx("ARTISTS", mbr.trackCredit.names()...)
+	txxx("MusicBrainz Artist Id", mbr.trackCredit.ids()...)
+	txxx("MusicBrainz Album Artist Id", mbr.albumCredit.ids()...)
+	txxx("MusicBrainz Work Id", mbr.work.workID)
+	txxx("MusicBrainz Album Status", strings.ToLower(mbr.status))
+	txxx("MusicBrainz Album Type", strings.ToLower(mbr.releaseType))
+	txxx("MusicBrainz Album Release Country", mbr.country)
+	txxx("BARCODE", mbr.barcode)
+	txxx("CATALOGNUMBER", mbr.catalogNumber)
+	txxx("SCRIPT", mbr.script)
+	return frames
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/otium/ytdl"
+	"github.com/pkg/errors"
+)
+
+var errNoFormat = errors.New("couldn't find a matching audio format")
+
+// formatPolicy decides which of the available formats of a video is
+// downloaded.
+type formatPolicy struct {
+	codec      string // preferred audio codec, empty for any
+	audioOnly  bool   // prefer audio only (DASH) formats over muxed video
+	minBitrate int
+	itag       int // pick this itag, ignore everything else
+}
+
+// audioFormat is the policy used for all downloads.
+var audioFormat formatPolicy
+
+// parseCodec maps the values of the -format flag to an audio codec.
+func parseCodec(format string) (string, error) {
+	switch strings.ToLower(format) {
+	case "", "best":
+		return "", nil
+	case "opus", "webm":
+		return "opus", nil
+	case "aac", "m4a", "mp4":
+		return "aac", nil
+	case "vorbis":
+		return "vorbis", nil
+	}
+	return "", errors.Errorf("unknown format %q", format)
+}
+
+func isAudioOnly(f ytdl.Format) bool {
+	return f.VideoEncoding == "" && f.Resolution == ""
+}
+
+// selectFormat returns the best format of formats according to the policy.
+// Formats without audio or below the bitrate floor are never chosen.
+func (p formatPolicy) selectFormat(formats ytdl.FormatList) (ytdl.Format, error) {
+	if p.itag != 0 {
+		for _, f := range formats {
+			if f.Itag == p.itag {
+				return f, nil
+			}
+		}
+		return ytdl.Format{}, errors.Errorf("itag %d is not available", p.itag)
+	}
+
+	var candidates ytdl.FormatList
+	for _, f := range formats {
+		if f.AudioEncoding != "" && f.AudioBitrate > 0 && f.AudioBitrate >= p.minBitrate {
+			candidates = append(candidates, f)
+		}
+	}
+	if len(candidates) == 0 {
+		return ytdl.Format{}, errNoFormat
+	}
+
+	rank := func(f ytdl.Format) int {
+		r := 0
+		if p.codec != "" && f.AudioEncoding == p.codec {
+			r += 2
+		}
+		if p.audioOnly && isAudioOnly(f) {
+			r++
+		}
+		return r
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		ri, rj := rank(candidates[i]), rank(candidates[j])
+		if ri != rj {
+			return ri > rj
+		}
+		return candidates[i].AudioBitrate > candidates[j].AudioBitrate
+	})
+	return candidates[0], nil
+}
+
+// listFormats prints all formats of a video, the one that would be downloaded
+// is marked with a star.
+func listFormats(vid *ytdl.VideoInfo) {
+	selected, _ := audioFormat.selectFormat(vid.Formats)
+
+	formats := vid.Formats.Copy()
+	formats.Sort(ytdl.FormatAudioBitrateKey, true)
+
+	fmt.Printf("%s:\n", vid.Title)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\titag\text\tresolution\tvideo\taudio\tbitrate")
+	for _, f := range formats {
+		mark := ""
+		if f.Itag == selected.Itag {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%d\n", mark, f.Itag, f.Extension, f.Resolution, f.VideoEncoding, f.AudioEncoding, f.AudioBitrate)
+	}
+	w.Flush()
+	fmt.Println()
+}
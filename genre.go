@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// genrePolicy decides which musicbrainz genres and tags are written as TCON.
+type genrePolicy struct {
+	max       int             // 0 disables genres
+	whitelist map[string]bool // only allow these, if empty the musicbrainz genres are allowed
+	aliases   map[string]string
+}
+
+// genreTags is used for all tracks.
+var genreTags = genrePolicy{max: 3}
+
+// parseGenreList parses a comma separated list of genres.
+func parseGenreList(list string) map[string]bool {
+	genres := make(map[string]bool)
+	for _, g := range strings.Split(list, ",") {
+		if g = strings.ToLower(strings.TrimSpace(g)); g != "" {
+			genres[g] = true
+		}
+	}
+	return genres
+}
+
+// parseGenreAliases parses a comma separated list of from=to pairs.
+func parseGenreAliases(list string) (map[string]string, error) {
+	aliases := make(map[string]string)
+	for _, a := range strings.Split(list, ",") {
+		if a = strings.TrimSpace(a); a == "" {
+			continue
+		}
+		kv := strings.SplitN(a, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid genre alias %q, expected from=to", a)
+		}
+		aliases[strings.ToLower(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+	return aliases, nil
+}
+
+// name returns how a genre is written, e.g. "Hip Hop" for "hip hop".
+func (p genrePolicy) name(genre string) string {
+	if alias, ok := p.aliases[genre]; ok {
+		return alias
+	}
+	return strings.Title(genre)
+}
+
+// genreSource is an entity whose genres and tags are looked up. Genres of the
+// release count more than the ones of its artist.
+type genreSource struct {
+	entity string
+	id     string
+	weight int
+}
+
+// getGenres returns the most popular genres of a release, its release group
+// and its artists. The keywords of the video are used if musicbrainz knows
+// none. Genres are optional, so failures are only reported.
+func getGenres(client *musicBrainz, release, group gomusicbrainz.MBID, artistIDs []string, keywords []string) []string {
+	if genreTags.max <= 0 {
+		return nil
+	}
+
+	sources := []genreSource{{"release", string(release), 3}, {"release-group", string(group), 2}}
+	for _, id := range artistIDs {
+		sources = append(sources, genreSource{"artist", id, 1})
+	}
+
+	votes := make(map[string]int)
+	for _, s := range sources {
+		if s.id == "" {
+			continue
+		}
+		json, err := client.getJSON("looking up the genres", "/"+s.entity+"/"+s.id, url.Values{"inc": {"genres+tags"}})
+		if err != nil {
+			fmt.Printf("Warning: couldn't get the genres of the %s: %v\n", s.entity, err)
+			continue
+		}
+
+		value := gjson.ParseBytes(json)
+		for _, field := range []string{"genres", "tags"} {
+			value.Get(field).ForEach(func(key, g gjson.Result) bool {
+				name := strings.ToLower(g.Get("name").String())
+				// tags are free text, they are only used if they are whitelisted
+				if genreTags.allowed(name, field == "genres") {
+					votes[name] += int(g.Get("count").Int()) * s.weight
+				}
+				return true
+			})
+		}
+	}
+
+	if len(votes) == 0 && len(keywords) > 0 {
+		known := musicBrainzGenres(client)
+		for _, k := range keywords {
+			if k = strings.ToLower(strings.TrimSpace(k)); genreTags.allowed(k, known[k]) {
+				votes[k]++
+			}
+		}
+	}
+	return genreTags.top(votes)
+}
+
+// allowed reports whether name may be used as a genre. isGenre tells whether
+// musicbrainz knows it as a genre.
+func (p genrePolicy) allowed(name string, isGenre bool) bool {
+	if len(p.whitelist) > 0 {
+		return p.whitelist[name]
+	}
+	return isGenre
+}
+
+// top returns the genres with the most votes, aliases that end up with the
+// same name are merged.
+func (p genrePolicy) top(votes map[string]int) []string {
+	merged := make(map[string]int)
+	for g, v := range votes {
+		if v > 0 {
+			merged[p.name(g)] += v
+		}
+	}
+
+	genres := make([]string, 0, len(merged))
+	for g := range merged {
+		genres = append(genres, g)
+	}
+	sort.Slice(genres, func(i, j int) bool {
+		if merged[genres[i]] != merged[genres[j]] {
+			return merged[genres[i]] > merged[genres[j]]
+		}
+		return genres[i] < genres[j]
+	})
+
+	if len(genres) > p.max {
+		genres = genres[:p.max]
+	}
+	return genres
+}
+
+// musicBrainzGenres returns all genres musicbrainz knows.
+func musicBrainzGenres(client *musicBrainz) map[string]bool {
+	genres := make(map[string]bool)
+	list, err := client.get("getting the genres", "/genre/all", url.Values{"fmt": {"txt"}})
+	if err != nil {
+		return genres
+	}
+	for _, g := range strings.Split(string(list), "\n") {
+		if g = strings.TrimSpace(g); g != "" {
+			genres[g] = true
+		}
+	}
+	return genres
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// id3v2 keeps only one frame per id, but a tag needs a TXXX frame for every
+// custom field. rawFrames are therefore written into the saved tag directly.
+
+// rawFrame is a frame that is added to a saved tag by addRawFrames.
+type rawFrame struct {
+	id string
+	// body returns the frame body for the given id3v2 version
+	body func(version byte) []byte
+}
+
+// encodeText returns the encoding byte and the encoded text. id3v2.3 has no
+// utf-8, so utf-16 is used there.
+func encodeText(version byte, text string) (byte, []byte) {
+	if version >= 4 {
+		return 3, []byte(text)
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{0xff, 0xfe})
+	for _, c := range utf16.Encode([]rune(text)) {
+		binary.Write(&b, binary.LittleEndian, c)
+	}
+	return 1, b.Bytes()
+}
+
+func terminator(encoding byte) []byte {
+	if encoding == 1 {
+		return []byte{0, 0}
+	}
+	return []byte{0}
+}
+
+// txxxFrame is a user defined text frame. Several values are separated by
+// null bytes in id3v2.4 and by slashes in id3v2.3.
+func txxxFrame(desc string, values ...string) rawFrame {
+	return rawFrame{id: "TXXX", body: func(version byte) []byte {
+		sep := "\x00"
+		if version < 4 {
+			sep = "/"
+		}
+		enc, d := encodeText(version, desc)
+		_, v := encodeText(version, strings.Join(values, sep))
+
+		body := []byte{enc}
+		body = append(body, d...)
+		body = append(body, terminator(enc)...)
+		return append(body, v...)
+	}}
+}
+
+// ufidFrame is a unique file identifier of the given owner.
+func ufidFrame(owner, id string) rawFrame {
+	return rawFrame{id: "UFID", body: func(version byte) []byte {
+		body := append([]byte(owner), 0)
+		return append(body, id...)
+	}}
+}
+
+// synchsafe encodes n with 7 bits per byte.
+func synchsafe(n int) []byte {
+	return []byte{byte(n>>21) & 0x7f, byte(n>>14) & 0x7f, byte(n>>7) & 0x7f, byte(n) & 0x7f}
+}
+
+func unsynchsafe(b []byte) int {
+	return int(b[0])<<21 | int(b[1])<<14 | int(b[2])<<7 | int(b[3])
+}
+
+// addRawFrames inserts frames at the beginning of the id3v2 tag of the file.
+func addRawFrames(path string, frames []rawFrame) error {
+	if len(frames) == 0 {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "couldn't read the tagged file")
+	}
+	if len(data) < 10 || string(data[:3]) != "ID3" {
+		return errors.New("the file has no id3v2 tag")
+	}
+	version := data[3]
+	if version != 3 && version != 4 {
+		return errors.Errorf("unsupported id3v2 version 2.%d", version)
+	}
+	if data[5]&0xc0 != 0 {
+		return errors.New("unsynchronised id3v2 tags and extended headers are not supported")
+	}
+
+	var raw bytes.Buffer
+	for _, f := range frames {
+		body := f.body(version)
+		raw.WriteString(f.id)
+		if version == 4 {
+			raw.Write(synchsafe(len(body)))
+		} else {
+			binary.Write(&raw, binary.BigEndian, uint32(len(body)))
+		}
+		raw.Write([]byte{0, 0})
+		raw.Write(body)
+	}
+
+	var out bytes.Buffer
+	out.Write(data[:6])
+	out.Write(synchsafe(unsynchsafe(data[6:10]) + raw.Len()))
+	out.Write(raw.Bytes())
+	out.Write(data[10:])
+
+	tmp := path + ".part"
+	if err := ioutil.WriteFile(tmp, out.Bytes(), 0666); err != nil {
+		return errors.Wrap(err, "couldn't write the tagged file")
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/pkg/errors"
+)
+
+var errNoTimestamps = errors.New("couldn't find any timestamps")
+
+// isLocalFile reports whether arg is a file on disk instead of a youtube url.
+func isLocalFile(arg string) bool {
+	info, err := os.Stat(arg)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// fileQuery returns the name of a file without its extension. It is used as
+// the musicbrainz search query for local files.
+func fileQuery(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// readTracklist returns the inline tracklist or the content of the tracklist
+// file.
+func readTracklist(file, inline string) (string, error) {
+	if inline != "" {
+		return strings.Replace(inline, `\n`, "\n", -1), nil
+	}
+	if file == "" {
+		return "", nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", errors.Wrap(err, "couldn't read the tracklist")
+	}
+	return string(data), nil
+}
+
+// splitLocalRelease splits an album file on disk. The cut points come from the
+// cue sheet or the tracklist. inputFile may be a cue sheet itself, then the file
+// it refers to is split.
+func splitLocalRelease(library, inputFile, cueFile, tracklist string, release gomusicbrainz.MBID, meta metadataProvider) error {
+	var tracks []float64
+	var titles []string
+	query := fileQuery(inputFile)
+
+	if strings.EqualFold(filepath.Ext(inputFile), ".cue") {
+		cueFile = inputFile
+	}
+
+	if cueFile != "" {
+		sheet, err := parseCue(cueFile)
+		if err != nil {
+			return errors.Wrap(err, "parseCue failed")
+		}
+		tracks, titles = sheet.offsets()
+
+		if inputFile == cueFile {
+			inputFile = sheet.file
+			query = sheet.query()
+		}
+	} else {
+		var length time.Duration
+		if l, err := getLength(inputFile); err == nil {
+			length = time.Duration(l) * time.Second
+		}
+		tracks, titles = parseTimestamps(tracklist, length)
+	}
+
+	if len(tracks) == 0 {
+		return errNoTimestamps
+	}
+
+	hints := releaseHints{mbid: release, trackCount: len(tracks), titles: titles}
+	if l, err := getLength(inputFile); err == nil {
+		hints.length = time.Duration(l) * time.Second
+		hints.lengths = trackLengths(tracks, hints.length)
+	}
+
+	mbr, err := meta.findRelease(query, hints)
+	handleError(err)
+	checkTrackTitles(titles, mbr)
+
+	dlFolder := albumFolder(library, mbr.title, mbr.releaseTags)
+	handleError(os.MkdirAll(dlFolder, 0777))
+	mbr.cover = loadCover(mbr.id, mbr.groupID, nil, dlFolder)
+
+	fmt.Println("\nExtracting tracks:")
+	return extractTracks(inputFile, "", tracks, mbr, dlFolder)
+}
+
+func convertLocalRecord(library, inputFile string, recording gomusicbrainz.MBID, meta metadataProvider) error {
+	hints := trackHints{mbid: recording}
+	if l, err := getLength(inputFile); err == nil {
+		hints.length = time.Duration(l) * time.Second
+	}
+
+	mbr, err := meta.findTrack(fileQuery(inputFile), hints)
+	handleError(err)
+
+	dlFolder := albumFolder(library, mbr.albumTitle, mbr.releaseTags)
+	handleError(os.MkdirAll(dlFolder, 0777))
+	mbr.cover = loadCover(mbr.releaseID, mbr.groupID, nil, dlFolder)
+
+	return convertTrack(inputFile, mbr, dlFolder)
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/url"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"os"
 
@@ -32,38 +35,186 @@ func handleError(err error) {
 	}
 }
 
-func dlRelease(library, url string, client *gomusicbrainz.WS2Client, vid *ytdl.VideoInfo) error {
-	mbr, err := getAlbumInfo(client, vid.Title)
+func dlRelease(library, url, cueFile string, release gomusicbrainz.MBID, meta metadataProvider, vid *ytdl.VideoInfo) error {
+	var tracks []float64
+	var titles []string
+	var err error
+	if cueFile != "" {
+		sheet, err := parseCue(cueFile)
+		handleError(err)
+		tracks, titles = sheet.offsets()
+	} else {
+		tracks, titles, err = getTracks(url, vid)
+		handleError(err)
+	}
+
+	provided, _ := parseProvidedDescription(vid.Description)
+	mbr, err := meta.findRelease(vid.Title, releaseHints{
+		mbid:       release,
+		author:     vid.Author,
+		provided:   provided,
+		trackCount: len(tracks),
+		length:     vid.Duration,
+		titles:     titles,
+		lengths:    trackLengths(tracks, vid.Duration),
+		keywords:   vid.Keywords,
+	})
 	handleError(err)
+	checkTrackTitles(titles, mbr)
 
-	dlFolder := filepath.Join(library, norma.Sanitize(mbr.artist), norma.Sanitize(mbr.title))
+	dlFolder := albumFolder(library, mbr.title, mbr.releaseTags)
 	dlFile := filepath.Join(dlFolder, norma.Sanitize(mbr.title))
 	defer os.Remove(dlFile)
 
-	tracks, err := getTracks(url)
-	handleError(err)
+	mbr.cover = loadCover(mbr.id, mbr.groupID, vid, dlFolder)
 
 	fmt.Println("\nDownloading Video:")
-	err = download(vid, dlFile)
+	input, err := download(vid, dlFile)
 	handleError(err)
 
 	fmt.Println("\nExtracting tracks:")
-	return extractTracks(dlFile, tracks, mbr, dlFolder)
+	return extractTracks(input, vid.ID, tracks, mbr, dlFolder)
 }
 
-func dlRecord(library string, client *gomusicbrainz.WS2Client, vid *ytdl.VideoInfo) error {
-	mbr, err := getTrackInfo(client, vid.Title)
+func dlRecord(library string, stream bool, recording gomusicbrainz.MBID, meta metadataProvider, vid *ytdl.VideoInfo) error {
+	provided, _ := parseProvidedDescription(vid.Description)
+	mbr, err := meta.findTrack(vid.Title, trackHints{
+		mbid:     recording,
+		author:   vid.Author,
+		provided: provided,
+		length:   vid.Duration,
+		keywords: vid.Keywords,
+	})
 	handleError(err)
 
-	dlFolder := filepath.Join(library, norma.Sanitize(mbr.albumArtist), norma.Sanitize(mbr.albumTitle))
+	dlFolder := albumFolder(library, mbr.albumTitle, mbr.releaseTags)
+	mbr.cover = loadCover(mbr.releaseID, mbr.groupID, vid, dlFolder)
+
+	if stream {
+		format, err := audioFormat.selectFormat(vid.Formats)
+		handleError(err)
+
+		// fall back to a temp file if ffmpeg has to probe the video first
+		_, cached := videoCache.get(vid.ID, format.Itag)
+		if canStream(format) && !cached {
+			fmt.Println("\nDownloading and transcoding Video:")
+			return streamTrack(vid, format, mbr, dlFolder)
+		}
+	}
+
 	dlFile := filepath.Join(dlFolder, norma.Sanitize(mbr.trackTitle))
 	defer os.Remove(dlFile)
 
 	fmt.Println("\nDownloading Video:")
-	err = download(vid, dlFile)
+	input, err := download(vid, dlFile)
 	handleError(err)
 
-	return convertTrack(dlFile, mbr, dlFolder)
+	return convertTrack(input, mbr, dlFolder)
+}
+
+// dlPlaylistRelease downloads an album that was uploaded as a playlist with one
+// video per track. The release is looked up once and every video is mapped to
+// its track.
+func dlPlaylistRelease(library string, release gomusicbrainz.MBID, meta metadataProvider, pl playlist) error {
+	fmt.Printf("Playlist: %s (%d videos)\n", pl.title, len(pl.entries))
+	hints := releaseHints{mbid: release, trackCount: len(pl.entries)}
+	for _, e := range pl.entries {
+		hints.length += time.Duration(e.length) * time.Second
+		hints.titles = append(hints.titles, e.title)
+		hints.lengths = append(hints.lengths, time.Duration(e.length)*time.Second)
+	}
+	mbr, err := meta.findRelease(pl.title, hints)
+	handleError(err)
+
+	dlFolder := albumFolder(library, mbr.title, mbr.releaseTags)
+	mbr.cover = loadCover(mbr.id, mbr.groupID, nil, dlFolder)
+
+	mapping := matchPlaylistTracks(pl.entries, mbr.tracks)
+	fmt.Println("\nTrack mapping:")
+	for i, e := range mapping {
+		video := "-"
+		if e >= 0 {
+			video = pl.entries[e].title
+		}
+		fmt.Printf("\t%.2d %s <- %s\n", i+1, mbr.tracks[i].Recording.Title, video)
+	}
+	if releaseMatcher.mode == matchAsk && !askForConfirmation("Download tracks?") {
+		return nil
+	}
+
+	for i, e := range mapping {
+		if e < 0 {
+			continue
+		}
+
+		vid, err := getVideoInfo(watchURL(pl.entries[e].id))
+		if err != nil {
+			return errors.Wrap(err, "GetVideoInfo failed")
+		}
+
+		rec := mbr.recording(i)
+		dlFile := filepath.Join(dlFolder, norma.Sanitize(rec.trackTitle))
+		fmt.Printf("\nDownloading Video %d/%d:\n", i+1, len(mbr.tracks))
+		input, err := download(vid, dlFile)
+		if err != nil {
+			return err
+		}
+
+		err = convertTrack(input, rec, dlFolder)
+		os.Remove(dlFile)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// source is an album or track from the command line together with the
+// musicbrainz release or recording that was given for it.
+type source struct {
+	arg       string
+	release   gomusicbrainz.MBID
+	recording gomusicbrainz.MBID
+}
+
+// parseSources groups the arguments. A musicbrainz url belongs to the argument
+// before it, the release and recording flags apply to all other arguments.
+func parseSources(args []string, release, recording string) ([]source, error) {
+	var defaults source
+	var ok bool
+	if release != "" {
+		if defaults.release, ok = parseMBID("release", release); !ok {
+			return nil, errors.Errorf("invalid musicbrainz release %q", release)
+		}
+	}
+	if recording != "" {
+		if defaults.recording, ok = parseMBID("recording", recording); !ok {
+			return nil, errors.Errorf("invalid musicbrainz recording %q", recording)
+		}
+	}
+
+	var sources []source
+	for _, arg := range args {
+		if _, ok := musicBrainzURL(arg); !ok {
+			src := defaults
+			src.arg = arg
+			sources = append(sources, src)
+			continue
+		}
+
+		if len(sources) == 0 {
+			return nil, errors.Errorf("%s doesn't follow an album or track", arg)
+		}
+		src := &sources[len(sources)-1]
+		if id, ok := parseMBID("release", arg); ok {
+			src.release = id
+		} else if id, ok := parseMBID("recording", arg); ok {
+			src.recording = id
+		} else {
+			return nil, errors.Errorf("%s is neither a musicbrainz release nor a recording", arg)
+		}
+	}
+	return sources, nil
 }
 
 func main() {
@@ -78,13 +229,60 @@ func main() {
 
 	dlTrack := flag.Bool("track", false, "download a single track from youtube")
 	dlAlbum := flag.Bool("album", true, "download a complete album from youtube")
+	plAlbum := flag.Bool("playlist-album", false, "download a playlist with one video per track as a single album")
 	printVersion := flag.Bool("version", false, "print the version and quit")
 	libraryPath := flag.String("lib", defaultLibraryPath, "the path to your music library")
+	timestampFile := flag.String("timestamps", "", "a file with the tracklist of a local album file")
+	tracklist := flag.String("tracklist", "", "the tracklist of a local album file, one track per line")
+	cueFile := flag.String("cue", "", "take the track offsets from a cue sheet")
+	codec := flag.String("format", "best", "the preferred audio format of the download: best, opus or aac")
+	audioOnly := flag.Bool("audio-only", false, "prefer audio only formats over videos")
+	minBitrate := flag.Int("min-bitrate", 0, "never download formats with a lower audio bitrate (kbit/s)")
+	itag := flag.Int("itag", 0, "download the format with this itag")
+	printFormats := flag.Bool("list-formats", false, "list the available formats instead of downloading")
+	auto := flag.Bool("auto", false, "choose the best release without asking if its score is high enough")
+	yes := flag.Bool("yes", false, "never ask, fail if no release has a high enough score")
+	minScore := flag.Float64("min-score", releaseMatcher.threshold, "the score (0-1) a release needs to be chosen automatically")
+	cacheDir := flag.String("cache-dir", defaultCacheDir(), "the directory downloaded videos and web service responses are cached in")
+	cacheSize := flag.Int64("cache-size", 2048, "the maximum size of the cache in MB, 0 for no limit")
+	noCache := flag.Bool("no-cache", false, "don't cache downloaded videos")
+	mbCacheTTL := flag.Duration("mb-cache-ttl", 24*time.Hour, "how long musicbrainz and discogs responses are cached, 0 to disable the cache")
+	mbRate := flag.Float64("mb-rate", 1, "the maximum number of musicbrainz requests per second")
+	mbURL := flag.String("mb-url", "https://musicbrainz.org/ws/2", "the musicbrainz web service, e.g. a local mirror")
+	mbContact := flag.String("mb-contact", contactURL, "the contact url or email sent to musicbrainz in the User-Agent")
+	mbTimeout := flag.Duration("mb-timeout", 30*time.Second, "the timeout of a musicbrainz request")
+	providers := flag.String("providers", "musicbrainz", "the metadata providers that are tried in this order: musicbrainz, discogs, manual")
+	discogsURL := flag.String("discogs-url", "https://api.discogs.com", "the discogs api")
+	discogsToken := flag.String("discogs-token", "", "the personal access token the discogs api needs")
+	manualFile := flag.String("manual-file", "", "the json or yaml file with the releases of the manual provider")
+	noCover := flag.Bool("no-cover", false, "don't fetch and embed cover art")
+	coverURL := flag.String("cover-art-url", coverArtURL, "the Cover Art Archive the covers are fetched from")
+	genres := flag.Int("genres", genreTags.max, "the maximum number of genres written to the tags, 0 for none")
+	genreWhitelist := flag.String("genre-whitelist", "", "a comma separated list of the allowed genres, the musicbrainz genres if empty")
+	genreAliases := flag.String("genre-aliases", "", "a comma separated list of genre renames, e.g. \"hip hop=Hip-Hop\"")
+	works := flag.Bool("works", false, "look up the works, composers and lyricists of the tracks")
+	stream := flag.Bool("stream", false, "transcode single tracks while they are downloaded")
+	retries := flag.Int("retries", netRetry.attempts, "how often a network operation is attempted")
+	retryBackoff := flag.Duration("retry-backoff", netRetry.backoff, "the delay before the first retry, doubled for every further retry")
+	retryMaxBackoff := flag.Duration("retry-max-backoff", netRetry.maxBackoff, "the maximum delay between two retries")
+	retryJitter := flag.Float64("retry-jitter", netRetry.jitter, "the fraction of the retry delay that is randomized")
+	retryStatus := flag.String("retry-status", "429,500,502,503,504", "the http status codes that are retried")
+	whole := flag.Bool("whole-release", false, "match albums against all discs of a release instead of a single disc")
+	folderDate := flag.String("folder-year", folderYear, "the year appended to album folders: none, release or original")
+	discDirs := flag.Bool("disc-folders", false, "put every disc of a multi-disc release into its own folder")
+	release := flag.String("release", "", "use this musicbrainz release (MBID or url) instead of searching")
+	recording := flag.String("recording", "", "use this musicbrainz recording (MBID or url) instead of searching")
 
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] [album1 album2 ... albumN]\n\nParameters:\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [options] [album1 album2 ... albumN]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "       %s [options] cache [list | purge [videoID ...]]\n\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "An album can be a youtube url, a playlist url, a local audio/video file or a cue sheet.\n")
+		fmt.Fprintf(os.Stderr, "A musicbrainz release or recording url following an album or track is used instead of searching.\n\n")
+		fmt.Fprintf(os.Stderr, "Parameters can also be set in %s (one \"name = value\" per line)\n", defaultConfigFile())
+		fmt.Fprintf(os.Stderr, "or in environment variables like %s, the command line overrides both.\n\nParameters:\n", envName("mb-url"))
 		flag.PrintDefaults()
 	}
+	handleError(loadConfig(defaultConfigFile()))
 	flag.Parse()
 
 	if *printVersion {
@@ -92,17 +290,109 @@ func main() {
 		os.Exit(0)
 	}
 
-	client, err := gomusicbrainz.NewWS2Client("https://musicbrainz.org/ws/2", appName, version, contactURL)
+	netRetry.attempts = *retries
+	netRetry.backoff = *retryBackoff
+	netRetry.maxBackoff = *retryMaxBackoff
+	netRetry.jitter = *retryJitter
+	netRetry.statusCodes, err = parseStatusCodes(*retryStatus)
+	handleError(err)
+
+	audioFormat.codec, err = parseCodec(*codec)
+	handleError(err)
+	audioFormat.audioOnly = *audioOnly
+	audioFormat.minBitrate = *minBitrate
+	audioFormat.itag = *itag
+
+	releaseMatcher.threshold = *minScore
+	if *yes {
+		releaseMatcher.mode = matchYes
+	} else if *auto {
+		releaseMatcher.mode = matchAuto
+	}
+
+	wholeRelease = *whole
+	if u, err := url.Parse(*mbURL); err == nil {
+		musicBrainzHost = u.Host
+	}
+	genreTags.max = *genres
+	genreTags.whitelist = parseGenreList(*genreWhitelist)
+	genreTags.aliases, err = parseGenreAliases(*genreAliases)
+	handleError(err)
+	lookupWorks = *works
+	fetchCovers = !*noCover
+	coverArtURL = strings.TrimSuffix(*coverURL, "/")
+	discFolders = *discDirs
+	folderYear, err = parseFolderYear(*folderDate)
+	handleError(err)
+
+	if !*noCache {
+		videoCache.dir = *cacheDir
+		videoCache.maxSize = *cacheSize << 20
+	}
+	if flag.Arg(0) == "cache" {
+		handleError(runCacheCommand(flag.Args()[1:]))
+		os.Exit(0)
+	}
+
+	client := newMusicBrainz(*mbURL, *mbContact, *mbTimeout, *mbRate, responseCache{
+		dir: filepath.Join(*cacheDir, ".musicbrainz"),
+		ttl: *mbCacheTTL,
+	})
+
+	meta, err := parseProviders(*providers, map[string]metadataProvider{
+		"musicbrainz": musicBrainzProvider{client},
+		"discogs": newDiscogs(*discogsURL, *discogsToken, *mbContact, responseCache{
+			dir: filepath.Join(*cacheDir, ".discogs"),
+			ttl: *mbCacheTTL,
+		}),
+		"manual": manualProvider{*manualFile},
+	})
 	handleError(err)
 
-	for _, url := range flag.Args() {
-		vid, err := ytdl.GetVideoInfo(url)
+	sources, err := parseSources(flag.Args(), *release, *recording)
+	handleError(err)
+
+	for _, src := range sources {
+		arg, cue := src.arg, *cueFile
+		// the cue sheet of a video that wasn't cached refers to its url
+		if video, ok := cueVideo(arg); ok && isLocalFile(arg) {
+			arg, cue = video, arg
+		}
+		if isLocalFile(arg) {
+			if *dlTrack {
+				handleError(convertLocalRecord(*libraryPath, arg, src.recording, meta))
+			} else if *dlAlbum {
+				text, err := readTracklist(*timestampFile, *tracklist)
+				handleError(err)
+				handleError(splitLocalRelease(*libraryPath, arg, cue, text, src.release, meta))
+			}
+			continue
+		}
+
+		if id, ok := getPlaylistID(arg); ok && *plAlbum && !*printFormats {
+			pl, err := getPlaylist(id)
+			handleError(err)
+			handleError(dlPlaylistRelease(*libraryPath, src.release, meta, pl))
+			continue
+		}
+
+		urls, err := expandURL(arg)
 		handleError(err)
 
-		if *dlTrack {
-			handleError(dlRecord(*libraryPath, client, vid))
-		} else if *dlAlbum {
-			handleError(dlRelease(*libraryPath, url, client, vid))
+		for _, url := range urls {
+			vid, err := getVideoInfo(url)
+			handleError(err)
+
+			if *printFormats {
+				listFormats(vid)
+				continue
+			}
+
+			if *dlTrack {
+				handleError(dlRecord(*libraryPath, *stream, src.recording, meta, vid))
+			} else if *dlAlbum {
+				handleError(dlRelease(*libraryPath, url, cue, src.release, meta, vid))
+			}
 		}
 	}
 }
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+	"gopkg.in/yaml.v2"
+)
+
+// manualProvider reads releases from a json or yaml file for music that no
+// database knows. The file contains a release or a list of releases:
+//
+//	{
+//		"artist": "Artist",
+//		"title": "Album",
+//		"date": "2020-03-20",
+//		"original-date": "1999",
+//		"label": "Label",
+//		"catalog-number": "CAT 1",
+//		"genres": ["Rock"],
+//		"tracks": [
+//			{"title": "Track", "artist": "Artist feat. Guest", "length": "3:45", "disc": 1}
+//		]
+//	}
+//
+// Files ending in .yaml or .yml use the same keys:
+//
+//	artist: Artist
+//	title: Album
+//	tracks:
+//	  - title: Track
+//	    length: 3:45
+//
+// Only the titles of the tracks are required.
+type manualProvider struct {
+	path string
+}
+
+func (p manualProvider) name() string {
+	return "manual"
+}
+
+func (p manualProvider) releases() ([]musicBrainzRelease, error) {
+	if p.path == "" {
+		return nil, errors.New("no release file given, see -manual-file")
+	}
+	data, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, errors.Wrap(err, "couldn't read the release file")
+	}
+	if ext := strings.ToLower(filepath.Ext(p.path)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return nil, errors.Wrapf(err, "%s is no valid yaml", p.path)
+		}
+	}
+	if !json.Valid(data) {
+		return nil, errors.Errorf("%s is no valid json", p.path)
+	}
+
+	value := gjson.ParseBytes(data)
+	list := []gjson.Result{value}
+	if strings.HasPrefix(strings.TrimSpace(value.Raw), "[") {
+		list = value.Array()
+	}
+
+	var releases []musicBrainzRelease
+	for _, r := range list {
+		releases = append(releases, manualRelease(r))
+	}
+	return releases, nil
+}
+
+// yamlToJSON converts a yaml document to json, so both are read the same way.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue(v))
+}
+
+// jsonValue replaces the maps of a decoded yaml document, which may have keys
+// of any type, with maps json can encode.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonValue(v[i])
+		}
+	}
+	return v
+}
+
+// manualCredit credits a single artist, join phrases are part of its name.
+func manualCredit(name string) artistCredit {
+	if name == "" {
+		return nil
+	}
+	return artistCredit{{Artist: creditedArtist{Name: name}}}
+}
+
+func manualRelease(release gjson.Result) musicBrainzRelease {
+	tags := releaseTags{
+		albumCredit:   manualCredit(release.Get("artist").String()),
+		date:          release.Get("date").String(),
+		originalDate:  release.Get("original-date").String(),
+		label:         release.Get("label").String(),
+		catalogNumber: release.Get("catalog-number").String(),
+		barcode:       release.Get("barcode").String(),
+		country:       release.Get("country").String(),
+		genres:        jsonStrings(release, "genres"),
+	}
+
+	var tracks []plainTrack
+	release.Get("tracks").ForEach(func(key, t gjson.Result) bool {
+		track := plainTrack{
+			title:  t.Get("title").String(),
+			credit: manualCredit(t.Get("artist").String()),
+			disc:   int(t.Get("disc").Int()),
+		}
+		if len(track.credit) == 0 {
+			track.credit = tags.albumCredit
+		}
+		if s, err := durToSec(t.Get("length").String()); err == nil {
+			track.length = time.Duration(s) * time.Second
+		}
+		tracks = append(tracks, track)
+		return true
+	})
+	return plainRelease(tags, release.Get("title").String(), tracks)
+}
+
+func (p manualProvider) findRelease(query string, hints releaseHints) (musicBrainzRelease, error) {
+	found, err := p.releases()
+	if err != nil {
+		return musicBrainzRelease{}, err
+	}
+
+	fmt.Printf("\nMatching release against %s: \n", p.path)
+	_, title := queryTitle(query, hints.author, hints.provided, true)
+	var releases []musicBrainzRelease
+	var candidates []candidate
+	for _, r := range found {
+		rs, cs := plainMedia(p.name(), r, int(100*titleSimilarity(title, r.title)), hints)
+		releases = append(releases, rs...)
+		candidates = append(candidates, cs...)
+	}
+
+	if i, ok := releaseMatcher.choose("Choose release?", candidates); ok {
+		return releases[i], nil
+	}
+	return musicBrainzRelease{}, errNoRelease
+}
+
+func (p manualProvider) findTrack(query string, hints trackHints) (musicBrainzRecording, error) {
+	found, err := p.releases()
+	if err != nil {
+		return musicBrainzRecording{}, err
+	}
+
+	fmt.Printf("\nMatching track against %s: \n", p.path)
+	artist, title := queryTitle(query, hints.author, hints.provided, false)
+	hints.title = title
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	for _, r := range found {
+		rs, cs := plainTracks(p.name(), r, int(100*titleSimilarity(artist, r.albumCredit.String())), hints)
+		recordings = append(recordings, rs...)
+		candidates = append(candidates, cs...)
+	}
+
+	if i, ok := releaseMatcher.choose("Choose track?", candidates); ok {
+		return recordings[i], nil
+	}
+	return musicBrainzRecording{}, errNoRelease
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const manualJSON = `[{
+	"artist": "Artist",
+	"title": "Album",
+	"date": "2020-03-20",
+	"original-date": "1999",
+	"label": "Label",
+	"genres": ["Rock", "Pop"],
+	"tracks": [
+		{"title": "First", "length": "3:45"},
+		{"title": "Second", "artist": "Artist feat. Guest", "length": "1:02:03", "disc": 2}
+	]
+}]`
+
+const manualYAML = `
+- artist: Artist
+  title: Album
+  date: 2020-03-20
+  original-date: 1999
+  label: Label
+  genres: [Rock, Pop]
+  tracks:
+    - title: First
+      length: 3:45
+    - title: Second
+      artist: Artist feat. Guest
+      length: "1:02:03"
+      disc: 2
+`
+
+func TestManualReleases(t *testing.T) {
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+
+	var found [][]musicBrainzRelease
+	for name, content := range map[string]string{"releases.json": manualJSON, "releases.yaml": manualYAML} {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0666); err != nil {
+			t.Fatal(err)
+		}
+		releases, err := manualProvider{path}.releases()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(releases) != 1 {
+			t.Fatalf("%s: %d releases", name, len(releases))
+		}
+
+		r := releases[0]
+		if r.title != "Album" || r.albumCredit.String() != "Artist" || r.date != "2020-03-20" || r.originalDate != "1999" {
+			t.Errorf("%s: release %q by %q from %q/%q", name, r.title, r.albumCredit, r.date, r.originalDate)
+		}
+		if !reflect.DeepEqual(r.genres, []string{"Rock", "Pop"}) {
+			t.Errorf("%s: genres = %q", name, r.genres)
+		}
+		if len(r.tracks) != 2 || r.tracks[0].Length != 225000 || r.tracks[1].Length != 3723000 {
+			t.Fatalf("%s: tracks = %+v", name, r.tracks)
+		}
+		if credit := r.recording(1).trackCredit.String(); credit != "Artist feat. Guest" {
+			t.Errorf("%s: the second track is credited to %q", name, credit)
+		}
+		if !reflect.DeepEqual(r.discs, []int{1, 2}) {
+			t.Errorf("%s: discs = %v", name, r.discs)
+		}
+		found = append(found, releases)
+	}
+	if !reflect.DeepEqual(found[0], found[1]) {
+		t.Errorf("the json and yaml files differ:\n%+v\n%+v", found[0], found[1])
+	}
+
+	path := filepath.Join(dir, "broken.yml")
+	if err := ioutil.WriteFile(path, []byte("tracks: [unclosed"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (manualProvider{path}).releases(); err == nil {
+		t.Error("a broken yaml file was accepted")
+	}
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+)
+
+type matchMode int
+
+const (
+	// matchAsk asks the user about every candidate
+	matchAsk matchMode = iota
+	// matchAuto accepts the best candidate if it is good enough and asks otherwise
+	matchAuto
+	// matchYes never asks, it fails if no candidate is good enough
+	matchYes
+)
+
+type matcher struct {
+	mode      matchMode
+	threshold float64
+}
+
+// releaseMatcher decides which musicbrainz release or recording is used.
+var releaseMatcher = matcher{threshold: 0.8}
+
+// candidate is a release or recording that may be chosen.
+type candidate struct {
+	desc  string // printed before the user is asked
+	score float64
+}
+
+// releaseHints describe the video a release is matched against. Zero values
+// are unknown and not taken into account. If mbid is set the release is known
+// and only its media are matched.
+type releaseHints struct {
+	mbid       gomusicbrainz.MBID
+	author     string // the channel that uploaded the video
+	provided   providedInfo
+	trackCount int
+	length     time.Duration
+	titles     []string
+	lengths    []time.Duration // the length of every track in the video
+	keywords   []string        // the keywords of the video, a fallback for genres
+}
+
+// trackHints describe the video a recording is matched against. If mbid is
+// set the recording is known and the search is skipped.
+type trackHints struct {
+	mbid     gomusicbrainz.MBID
+	author   string // the channel that uploaded the video
+	provided providedInfo
+	title    string
+	length   time.Duration
+	keywords []string // the keywords of the video, a fallback for genres
+}
+
+// weightedMean averages scores of different weight.
+type weightedMean struct {
+	sum    float64
+	weight float64
+}
+
+func (m *weightedMean) add(score, weight float64) {
+	m.sum += score * weight
+	m.weight += weight
+}
+
+func (m *weightedMean) value() float64 {
+	if m.weight == 0 {
+		return 0
+	}
+	return m.sum / m.weight
+}
+
+// lengthScore is 1 if the lengths are equal and drops to 0 when they differ by
+// tolerance or more.
+func lengthScore(a, b, tolerance time.Duration) float64 {
+	diff := math.Abs(float64(a - b))
+	return math.Max(0, 1-diff/float64(tolerance))
+}
+
+func mediumLength(m *gomusicbrainz.Medium) time.Duration {
+	var l time.Duration
+	for _, t := range m.Tracks {
+		l += trackLength(t)
+	}
+	return l
+}
+
+func trackLength(t *gomusicbrainz.Track) time.Duration {
+	if t.Length > 0 {
+		return time.Duration(t.Length) * time.Millisecond
+	}
+	return time.Duration(t.Recording.Length) * time.Millisecond
+}
+
+// trackLengths returns the lengths of the tracks that start at the given
+// offsets (in seconds) of a video. The last track ends with the video.
+func trackLengths(offsets []float64, total time.Duration) []time.Duration {
+	lengths := make([]time.Duration, len(offsets))
+	for i, start := range offsets {
+		end := total.Seconds()
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		if end > start {
+			lengths[i] = time.Duration((end - start) * float64(time.Second))
+		}
+	}
+	return lengths
+}
+
+// trackDeviation returns how much the i-th track of m is longer than the
+// track in the video. ok is false if one of the lengths is unknown.
+func trackDeviation(m *gomusicbrainz.Medium, i int, hints releaseHints) (time.Duration, bool) {
+	if i >= len(m.Tracks) || i >= len(hints.lengths) {
+		return 0, false
+	}
+	l := trackLength(m.Tracks[i])
+	if l == 0 || hints.lengths[i] == 0 {
+		return 0, false
+	}
+	return l - hints.lengths[i], true
+}
+
+// maxTrackDeviation is the difference in length above which a track is
+// considered a different one.
+const maxTrackDeviation = 30 * time.Second
+
+// mismatches describes why a medium obviously doesn't fit the video. It is
+// empty if the medium might fit.
+func mismatches(m *gomusicbrainz.Medium, hints releaseHints) []string {
+	var problems []string
+	if hints.trackCount > 0 && hints.trackCount != len(m.Tracks) {
+		problems = append(problems, fmt.Sprintf("%d tracks instead of %d", len(m.Tracks), hints.trackCount))
+	}
+	if l := mediumLength(m); hints.length > 0 && l > 0 && lengthScore(hints.length, l, hints.length/10) == 0 {
+		problems = append(problems, fmt.Sprintf("%s long instead of %s", l.Round(time.Second), hints.length.Round(time.Second)))
+	}
+
+	var off int
+	for i := range m.Tracks {
+		if d, ok := trackDeviation(m, i, hints); ok && (d > maxTrackDeviation || d < -maxTrackDeviation) {
+			off++
+		}
+	}
+	if off > 0 {
+		problems = append(problems, fmt.Sprintf("%d tracks differ by more than %s", off, maxTrackDeviation))
+	}
+	return problems
+}
+
+// scoreMedium rates how well a medium fits the video. The musicbrainz search
+// score, the track count, the total length, the length of every track and the
+// track titles are used.
+func scoreMedium(searchScore int, m *gomusicbrainz.Medium, hints releaseHints) float64 {
+	var mean weightedMean
+	mean.add(float64(searchScore)/100, 1)
+
+	if hints.trackCount > 0 {
+		diff := math.Abs(float64(hints.trackCount - len(m.Tracks)))
+		mean.add(math.Max(0, 1-diff/float64(hints.trackCount)), 2)
+	}
+
+	if l := mediumLength(m); hints.length > 0 && l > 0 {
+		mean.add(lengthScore(hints.length, l, hints.length/10), 2)
+	}
+
+	if len(hints.lengths) > 0 {
+		var sum float64
+		for i := range hints.lengths {
+			if d, ok := trackDeviation(m, i, hints); ok {
+				sum += lengthScore(d, 0, maxTrackDeviation)
+			}
+		}
+		mean.add(sum/float64(len(hints.lengths)), 2)
+	}
+
+	if len(hints.titles) > 0 {
+		var sim float64
+		for i, t := range hints.titles {
+			if i < len(m.Tracks) {
+				sim += titleSimilarity(t, m.Tracks[i].Recording.Title)
+			}
+		}
+		mean.add(sim/float64(len(hints.titles)), 2)
+	}
+
+	return mean.value()
+}
+
+// scoreRecording rates how well a recording fits the video.
+func scoreRecording(searchScore int, title string, length time.Duration, hints trackHints) float64 {
+	var mean weightedMean
+	mean.add(float64(searchScore)/100, 1)
+
+	if hints.title != "" {
+		mean.add(titleSimilarity(hints.title, title), 1)
+	}
+	if hints.length > 0 && length > 0 {
+		mean.add(lengthScore(hints.length, length, 30*time.Second), 1)
+	}
+	return mean.value()
+}
+
+// choose returns the index of the chosen candidate. Depending on the mode the
+// best candidate is taken without asking if its score reaches the threshold.
+func (m matcher) choose(question string, candidates []candidate) (int, bool) {
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return candidates[order[i]].score > candidates[order[j]].score
+	})
+
+	if len(order) > 0 && m.mode != matchAsk {
+		best := candidates[order[0]]
+		if best.score >= m.threshold {
+			fmt.Printf("%sScore: %.2f (chosen automatically)\n\n", best.desc, best.score)
+			return order[0], true
+		}
+		if m.mode == matchYes {
+			fmt.Printf("No candidate reached a score of %.2f, the best one scored %.2f\n", m.threshold, best.score)
+			return -1, false
+		}
+	}
+
+	for _, i := range order {
+		fmt.Printf("%sScore: %.2f\n", candidates[i].desc, candidates[i].score)
+		if askForConfirmation(question) {
+			return i, true
+		}
+		fmt.Println()
+	}
+	return -1, false
+}
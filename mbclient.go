@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/pkg/errors"
+)
+
+// tokenBucket allows rate requests per second with bursts of up to burst
+// requests.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+// wait blocks until a token is available and takes it.
+func (b *tokenBucket) wait() {
+	if b == nil || b.rate <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		time.Sleep(wait)
+		b.tokens = 1
+		b.last = time.Now()
+	}
+	b.tokens--
+}
+
+// responseCache keeps web service responses in dir. Responses older than ttl
+// are requested again.
+type responseCache struct {
+	dir string
+	ttl time.Duration
+}
+
+func (c responseCache) enabled() bool {
+	return c.dir != "" && c.ttl > 0
+}
+
+func (c responseCache) path(url string) string {
+	sum := sha1.Sum([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
+
+func (c responseCache) get(url string) ([]byte, bool) {
+	if !c.enabled() {
+		return nil, false
+	}
+
+	path := c.path(url)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > c.ttl {
+		return nil, false
+	}
+	data, err := ioutil.ReadFile(path)
+	return data, err == nil
+}
+
+func (c responseCache) put(url string, data []byte) {
+	if !c.enabled() {
+		return
+	}
+
+	os.MkdirAll(c.dir, 0777)
+	path := c.path(url)
+	// write to a temp file first, a concurrent reader never sees half a response
+	if err := ioutil.WriteFile(path+".part", data, 0666); err == nil {
+		os.Rename(path+".part", path)
+	}
+}
+
+// musicBrainz is the only way the musicbrainz web service is accessed. All
+// requests share one rate limit and successful responses are cached.
+type musicBrainz struct {
+	baseURL   string
+	userAgent string
+	client    *http.Client
+	limiter   *tokenBucket
+	cache     responseCache
+}
+
+func newMusicBrainz(baseURL, contact string, timeout time.Duration, rate float64, cache responseCache) *musicBrainz {
+	return &musicBrainz{
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		userAgent: fmt.Sprintf("%s/%s ( %s )", appName, version, contact),
+		client:    &http.Client{Timeout: timeout},
+		limiter:   newTokenBucket(rate, 1),
+		cache:     cache,
+	}
+}
+
+// get requests endpoint with the given parameters. what describes the request
+// in retry messages.
+func (mb *musicBrainz) get(what, endpoint string, params url.Values) ([]byte, error) {
+	header := http.Header{"User-Agent": {mb.userAgent}}
+	return fetch(mb.client, mb.limiter, mb.cache, what, mb.baseURL+endpoint, params, header)
+}
+
+// fetch requests a web service. The requests of a service share a rate limit
+// and successful responses are cached. The header isn't part of the cache key.
+func fetch(client *http.Client, limiter *tokenBucket, cache responseCache, what, reqURL string, params url.Values, header http.Header) ([]byte, error) {
+	if len(params) > 0 {
+		reqURL += "?" + params.Encode()
+	}
+	if data, ok := cache.get(reqURL); ok {
+		return data, nil
+	}
+
+	req, err := http.NewRequest("GET", reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = header
+
+	var data []byte
+	err = netRetry.do(what, func() error {
+		limiter.wait()
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newStatusError(resp)
+		}
+
+		data, err = ioutil.ReadAll(resp.Body)
+		return errors.Wrap(err, "reading response body failed")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cache.put(reqURL, data)
+	return data, nil
+}
+
+// getJSON requests endpoint in json format.
+func (mb *musicBrainz) getJSON(what, endpoint string, params url.Values) ([]byte, error) {
+	params.Set("fmt", "json")
+	return mb.get(what, endpoint, params)
+}
+
+// getXML requests endpoint in xml format and decodes the response into
+// every v.
+func (mb *musicBrainz) getXML(what, endpoint string, params url.Values, v ...interface{}) error {
+	data, err := mb.get(what, endpoint, params)
+	if err != nil {
+		return err
+	}
+	for _, v := range v {
+		if err := xml.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+			return errors.Wrap(err, "invalid response")
+		}
+	}
+	return nil
+}
+
+// searchRelease searches releases like gomusicbrainz.WS2Client.SearchRelease.
+func (mb *musicBrainz) searchRelease(query string, limit int) (*gomusicbrainz.ReleaseSearchResponse, error) {
+	var result struct {
+		XMLName  xml.Name `xml:"metadata"`
+		Releases []struct {
+			*gomusicbrainz.Release
+			Score int `xml:"http://musicbrainz.org/ns/ext#-2.0 score,attr"`
+		} `xml:"release-list>release"`
+	}
+
+	params := url.Values{"query": {query}, "limit": {fmt.Sprint(limit)}}
+	if err := mb.getXML("searching the release", "/release/", params, &result); err != nil {
+		return nil, errors.Wrap(err, "SearchRelease failed")
+	}
+
+	resp := &gomusicbrainz.ReleaseSearchResponse{Scores: make(gomusicbrainz.ScoreMap)}
+	for _, v := range result.Releases {
+		resp.Releases = append(resp.Releases, v.Release)
+		resp.Scores[v.Release] = v.Score
+	}
+	return resp, nil
+}
+
+// releaseCredits are the artist credits of a release and its tracks.
+type releaseCredits struct {
+	release artistCredit
+	tracks  map[gomusicbrainz.MBID]artistCredit
+	works   map[gomusicbrainz.MBID]workCredits // by track id
+}
+
+// lookupRelease looks up a release like gomusicbrainz.WS2Client.LookupRelease.
+// The artist credits are only complete if "artist-credits" is included, the
+// works are only known if workIncludes are included.
+func (mb *musicBrainz) lookupRelease(id gomusicbrainz.MBID, inc ...string) (*gomusicbrainz.Release, releaseCredits, error) {
+	var result struct {
+		XMLName xml.Name               `xml:"metadata"`
+		Release *gomusicbrainz.Release `xml:"release"`
+	}
+	var credits struct {
+		XMLName xml.Name     `xml:"metadata"`
+		Credit  artistCredit `xml:"release>artist-credit>name-credit"`
+		Tracks  []struct {
+			ID              gomusicbrainz.MBID `xml:"id,attr"`
+			Credit          artistCredit       `xml:"artist-credit>name-credit"`
+			RecordingCredit artistCredit       `xml:"recording>artist-credit>name-credit"`
+			Relations       []xmlRelation      `xml:"recording>relation-list>relation"`
+		} `xml:"release>medium-list>medium>track-list>track"`
+	}
+
+	params := url.Values{"inc": {strings.Join(inc, "+")}}
+	if err := mb.getXML("looking up the release", "/release/"+string(id), params, &result, &credits); err != nil {
+		return nil, releaseCredits{}, errors.Wrap(err, "LookupRelease failed")
+	}
+	if result.Release == nil {
+		return nil, releaseCredits{}, errors.Errorf("release %s not found", id)
+	}
+
+	rc := releaseCredits{
+		release: credits.Credit,
+		tracks:  make(map[gomusicbrainz.MBID]artistCredit),
+		works:   make(map[gomusicbrainz.MBID]workCredits),
+	}
+	for _, t := range credits.Tracks {
+		// a track is credited like its recording unless it says otherwise
+		rc.tracks[t.ID] = t.Credit
+		if len(t.Credit) == 0 {
+			rc.tracks[t.ID] = t.RecordingCredit
+		}
+		if w := xmlWork(t.Relations); w.workID != "" {
+			rc.works[t.ID] = w
+		}
+	}
+	return result.Release, rc, nil
+}
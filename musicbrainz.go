@@ -4,14 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"regexp"
+	"sort"
 	"strings"
 
 	"time"
 
-	"io/ioutil"
-
 	"github.com/michiwend/gomusicbrainz"
 	"github.com/pkg/errors"
 	"github.com/tidwall/gjson"
@@ -19,22 +19,91 @@ import (
 
 var errNoRelease = errors.New("couldn't find a release")
 
+// wholeRelease matches albums against all media of a release instead of a
+// single medium.
+var wholeRelease bool
+
+// releaseTags are the tags all tracks of a release share.
+type releaseTags struct {
+	albumCredit   artistCredit
+	date          string // as precise as known, e.g. "2006-01"
+	originalDate  string // the first release of the release group
+	status        string
+	releaseType   string
+	country       string
+	barcode       string
+	catalogNumber string
+	label         string
+	script        string
+	genres        []string
+}
+
 type musicBrainzRecording struct {
-	year         string
-	albumTitle   string
-	cdNum        int64
-	trackNum     int64
-	trackCount   int64
-	albumArtist  string
-	trackArtists []string
-	trackTitle   string
+	releaseTags
+	releaseID   gomusicbrainz.MBID
+	groupID     gomusicbrainz.MBID // the release group
+	trackID     gomusicbrainz.MBID // the track on the release
+	recordingID gomusicbrainz.MBID
+	cover       *coverArt
+	albumTitle  string
+	cdNum       int64
+	discCount   int64 // 0 if unknown
+	trackNum    int64
+	trackCount  int64
+	trackCredit artistCredit
+	trackTitle  string
+	work        workCredits
 }
 
 type musicBrainzRelease struct {
-	artist string
-	title  string
-	year   string
-	tracks []*gomusicbrainz.Track
+	releaseTags
+	id        gomusicbrainz.MBID
+	groupID   gomusicbrainz.MBID // the release group
+	cover     *coverArt
+	title     string
+	discCount int
+	tracks    []*gomusicbrainz.Track
+	credits   []artistCredit                     // the artist credit of every track
+	discs     []int                              // the medium position of every track
+	works     map[gomusicbrainz.MBID]workCredits // by track id
+}
+
+// recording returns the tags of the i-th track. Tracks are numbered per
+// medium.
+func (r musicBrainzRelease) recording(i int) musicBrainzRecording {
+	t := r.tracks[i]
+	rec := musicBrainzRecording{
+		releaseTags: r.releaseTags,
+		releaseID:   r.id,
+		groupID:     r.groupID,
+		trackID:     t.ID,
+		recordingID: t.Recording.ID,
+		cover:       r.cover,
+		albumTitle:  r.title,
+		cdNum:       -1,
+		trackNum:    int64(i + 1),
+		trackCount:  int64(len(r.tracks)),
+		discCount:   int64(r.discCount),
+		trackTitle:  t.Recording.Title,
+		work:        r.works[t.ID],
+	}
+	if i < len(r.credits) {
+		rec.trackCredit = r.credits[i]
+	}
+
+	if i < len(r.discs) {
+		rec.cdNum = int64(r.discs[i])
+		rec.trackNum, rec.trackCount = 0, 0
+		for j, d := range r.discs {
+			if d == r.discs[i] {
+				rec.trackCount++
+				if j <= i {
+					rec.trackNum++
+				}
+			}
+		}
+	}
+	return rec
 }
 
 func scanLines(scanTo map[string]*string) {
@@ -49,155 +118,474 @@ func scanLines(scanTo map[string]*string) {
 	}
 }
 
-func getArtistAlbumOrTrack(query string) (artist string, release string) {
-	info := strings.Split(query, "-")
-	if len(info) > 1 {
-		artist = strings.TrimSpace(info[0])
-		release = strings.TrimSpace(info[1])
+var mbidRe = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// musicBrainzHost is the host of -mb-url, urls of a mirror are accepted like
+// the ones of musicbrainz.org.
+var musicBrainzHost string
+
+// musicBrainzURL parses s if it is a url of musicbrainz.org or of the
+// configured mirror.
+func musicBrainzURL(s string) (*url.URL, bool) {
+	if !strings.Contains(s, "://") {
+		s = "https://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, false
 	}
-	return
+	return u, strings.HasSuffix(u.Host, "musicbrainz.org") || strings.EqualFold(u.Host, musicBrainzHost)
 }
 
-func getTrackInfo(client *gomusicbrainz.WS2Client, query string) (musicBrainzRecording, error) {
-	var recording musicBrainzRecording
-	artist, track := getArtistAlbumOrTrack(query)
-	scanTo := map[string]*string{
-		"Artist": &artist,
-		"Track":  &track,
+// parseMBID accepts a bare MBID or a musicbrainz url of the given entity type,
+// e.g. https://musicbrainz.org/release/<MBID>.
+func parseMBID(entity, s string) (gomusicbrainz.MBID, bool) {
+	if u, ok := musicBrainzURL(s); ok {
+		parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+		if len(parts) < 2 || parts[0] != entity {
+			return "", false
+		}
+		s = parts[1]
+	}
+	if !mbidRe.MatchString(s) {
+		return "", false
 	}
+	return gomusicbrainz.MBID(strings.ToLower(s)), true
+}
 
-	scanLines(scanTo)
+// recordingReleases returns one recording per medium of the given releases
+// the json recording appears on.
+func recordingReleases(value gjson.Result, releases []gjson.Result, score float64) ([]musicBrainzRecording, []candidate) {
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+
+	trackTitle := value.Get("title").String()
+	credit := jsonCredit(value)
+
+	// search results don't know when a release group was first released,
+	// the earliest release of the group the recording appears on is the
+	// next best thing
+	firstRelease := make(map[string]string)
+	for _, release := range releases {
+		group, date := release.Get("release-group.id").String(), release.Get("date").String()
+		if first, ok := firstRelease[group]; date != "" && (!ok || date < first) {
+			firstRelease[group] = date
+		}
+	}
 
-	query = fmt.Sprintf(`artist:"%s" AND %s`, strings.TrimSpace(artist), strings.TrimSpace(track))
+	for _, release := range releases {
+		tags := jsonReleaseTags(release)
+		// releases of a recording only have an artist credit if it differs
+		if len(tags.albumCredit) == 0 {
+			tags.albumCredit = credit
+		}
+		if tags.originalDate == "" {
+			tags.originalDate = firstRelease[release.Get("release-group.id").String()]
+		}
 
-	req, err := http.NewRequest("GET", "https://musicbrainz.org/ws/2/recording/", nil)
-	if err != nil {
-		return recording, err
+		release.Get("media").ForEach(func(key, media gjson.Result) bool {
+			// search results list the track as "track", lookups as "tracks"
+			trackID := media.Get("track.0.id").String()
+			if trackID == "" {
+				trackID = media.Get("tracks.0.id").String()
+			}
+
+			recording := musicBrainzRecording{
+				releaseTags: tags,
+				releaseID:   gomusicbrainz.MBID(release.Get("id").String()),
+				groupID:     gomusicbrainz.MBID(release.Get("release-group.id").String()),
+				trackID:     gomusicbrainz.MBID(trackID),
+				recordingID: gomusicbrainz.MBID(value.Get("id").String()),
+				trackCredit: credit,
+				cdNum:       media.Get("position").Int(),
+				trackCount:  media.Get("track-count").Int(),
+				trackNum:    media.Get("track-offset").Int() + 1,
+				albumTitle:  release.Get("title").String(),
+				trackTitle:  trackTitle,
+			}
+
+			recordings = append(recordings, recording)
+			candidates = append(candidates, candidate{
+				desc: fmt.Sprintf("Release: %s (%s)\nFormat: %s\nTrack: %.2d/%.2d %s - %s\n",
+					recording.albumTitle, recording.date, media.Get("format").String(), recording.trackNum, recording.trackCount, credit, recording.trackTitle),
+				score: score,
+			})
+			return true
+		})
 	}
+	return recordings, candidates
+}
 
-	q := req.URL.Query()
-	q.Add("query", query)
-	q.Add("fmt", "json")
-	req.URL.RawQuery = q.Encode()
+// jsonStrings returns the strings at path, e.g. "artist-credit.#.artist.name".
+func jsonStrings(value gjson.Result, path string) []string {
+	var list []string
+	value.Get(path).ForEach(func(key, value gjson.Result) bool {
+		list = append(list, value.String())
+		return true
+	})
+	return list
+}
 
-	req.Header.Set("User-Agent", fmt.Sprintf("%s/%s ( %s )", appName, version, contactURL))
+func jsonReleaseTags(release gjson.Result) releaseTags {
+	return releaseTags{
+		albumCredit:   jsonCredit(release),
+		date:          release.Get("date").String(),
+		originalDate:  release.Get("release-group.first-release-date").String(),
+		status:        release.Get("status").String(),
+		releaseType:   release.Get("release-group.primary-type").String(),
+		country:       release.Get("country").String(),
+		barcode:       release.Get("barcode").String(),
+		catalogNumber: release.Get("label-info.0.catalog-number").String(),
+		label:         release.Get("label-info.0.label.name").String(),
+		script:        release.Get("text-representation.script").String(),
+	}
+}
 
-	fmt.Println("\nSearching track on musicbrainz: ")
+func xmlReleaseTags(rec *gomusicbrainz.Release, credit artistCredit) releaseTags {
+	tags := releaseTags{
+		albumCredit:  credit,
+		date:         brainzDate(rec.Date),
+		originalDate: brainzDate(rec.ReleaseGroup.FirstReleaseDate),
+		status:       rec.Status,
+		releaseType:  rec.ReleaseGroup.PrimaryType,
+		country:      rec.CountryCode,
+		barcode:      rec.Barcode,
+		script:       rec.TextRepresentation.Script,
+	}
+	if tags.releaseType == "" {
+		tags.releaseType = rec.ReleaseGroup.Type
+	}
+	if len(rec.LabelInfos) > 0 {
+		tags.catalogNumber = rec.LabelInfos[0].CatalogNumber
+		if rec.LabelInfos[0].Label != nil {
+			tags.label = rec.LabelInfos[0].Label.Name
+		}
+	}
+	return tags
+}
 
-	httpClient := &http.Client{
-		Timeout: 5 * time.Second,
+// brainzDate formats a date as precise as musicbrainz knows it. Unknown dates
+// are empty.
+func brainzDate(t gomusicbrainz.BrainzTime) string {
+	if t.IsZero() {
+		return ""
 	}
+	switch t.Accuracy {
+	case gomusicbrainz.Day:
+		return t.Format("2006-01-02")
+	case gomusicbrainz.Month:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006")
+}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return recording, err
+// dateYear returns the year of a musicbrainz date.
+func dateYear(date string) string {
+	if len(date) > 4 {
+		return date[:4]
 	}
-	defer resp.Body.Close()
+	return date
+}
 
-	json, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return recording, errors.Wrap(err, "reading response body failed")
+func getTrackInfo(client *musicBrainz, query string, hints trackHints) (musicBrainzRecording, error) {
+	if hints.mbid != "" {
+		return lookupTrackInfo(client, hints.mbid)
 	}
 
-	proceed := true
-	value := gjson.GetBytes(json, "recordings")
+	var recording musicBrainzRecording
+	t := parseVideoTitle(query, hints.author)
+	artist, track := t.artist, t.title
+	scanTo := map[string]*string{
+		"Artist": &artist,
+		"Track":  &track,
+	}
 
-	value.ForEach(func(key, value gjson.Result) bool {
+	// auto generated descriptions are more reliable than anything we could
+	// type in
+	p := hints.provided
+	if p.title != "" {
+		artist, track = strings.Join(p.artists, ", "), p.title
+		fmt.Printf("Artist: %s\nTrack: %s\nAlbum: %s\n", artist, track, p.album)
+	} else if releaseMatcher.mode == matchAsk {
+		scanLines(scanTo)
+	}
+	hints.title = track
+
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	var err error
+	if p.isrc != "" {
+		fmt.Printf("\nLooking up ISRC %s on musicbrainz: \n", p.isrc)
+		recordings, candidates, err = findRecordings(client, "looking up the ISRC", "/isrc/"+p.isrc,
+			url.Values{"inc": {"artist-credits+releases+release-groups+media"}}, hints)
+		// musicbrainz doesn't know every ISRC, the search may still find it
+		if e, ok := errors.Cause(err).(*statusError); ok && e.code == http.StatusNotFound {
+			fmt.Println("The ISRC is unknown")
+			err = nil
+		}
+		if err != nil {
+			return recording, err
+		}
+	}
 
-		var artists []string
-		value.Get("artist-credit.#.artist.name").ForEach(func(key, value gjson.Result) bool {
-			artists = append(artists, value.String())
-			return true
-		})
+	query = fmt.Sprintf(`artist:"%s" AND %s`, strings.TrimSpace(artist), strings.TrimSpace(track))
+	// guests are optional terms, they only improve the score of recordings
+	// that credit them
+	for _, g := range t.featured {
+		query += fmt.Sprintf(` artist:"%s"`, g)
+	}
+	queries := []string{query}
+	if p.album != "" {
+		queries = []string{fmt.Sprintf(`recording:"%s" AND release:"%s" AND artist:"%s"`, track, p.album, p.artists[0]), query}
+	}
 
-		trackTitle := value.Get("title").String()
-		value.Get("releases").ForEach(func(key, release gjson.Result) bool {
-			release.Get("media").ForEach(func(key, media gjson.Result) bool {
-
-				recording = musicBrainzRecording{
-					albumArtist:  artist,
-					trackArtists: artists,
-					cdNum:        media.Get("position").Int(),
-					trackCount:   media.Get("track-count").Int(),
-					trackNum:     media.Get("track-offset").Int() + 1,
-					albumTitle:   release.Get("title").String(),
-					year:         release.Get("date").String(),
-					trackTitle:   trackTitle,
-				}
+	for _, q := range queries {
+		if len(candidates) > 0 {
+			break
+		}
+		fmt.Println("\nSearching track on musicbrainz: ")
+		recordings, candidates, err = findRecordings(client, "searching the track", "/recording/",
+			url.Values{"query": {q}}, hints)
+		if err != nil {
+			return recording, err
+		}
+	}
 
-				if len(recording.year) >= 4 {
-					recording.year = recording.year[0:4]
-				}
+	if i, ok := releaseMatcher.choose("Choose track?", candidates); ok {
+		return recordings[i], nil
+	}
+	return recording, errNoRelease
+}
 
-				fmt.Printf("Release: %s (%s)\nFormat: %s\nTrack: %.2d/%.2d %s - %s\n",
-					recording.albumTitle, recording.year, media.Get("format").String(), recording.trackNum, recording.trackCount, artists, recording.trackTitle)
+// findRecordings requests a json list of recordings, e.g. a search result,
+// and returns a candidate for every medium they appear on.
+func findRecordings(client *musicBrainz, what, endpoint string, params url.Values, hints trackHints) ([]musicBrainzRecording, []candidate, error) {
+	json, err := client.getJSON(what, endpoint, params)
+	if err != nil {
+		return nil, nil, err
+	}
 
-				if askForConfirmation("Choose track?") {
-					proceed = false
-				}
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	gjson.GetBytes(json, "recordings").ForEach(func(key, value gjson.Result) bool {
+		// lookups have no search score, everything they return matches
+		searchScore := 100
+		if s := value.Get("score"); s.Exists() {
+			searchScore = int(s.Int())
+		}
 
-				fmt.Println()
-				return proceed
-			})
-			return proceed
-		})
-		return proceed
+		score := scoreRecording(searchScore, value.Get("title").String(),
+			time.Duration(value.Get("length").Int())*time.Millisecond, hints)
+
+		r, c := recordingReleases(value, value.Get("releases").Array(), score)
+		recordings = append(recordings, r...)
+		candidates = append(candidates, c...)
+		return true
 	})
 
-	if proceed == false {
-		return recording, nil
+	// prefer the album the description names
+	if album := hints.provided.album; album != "" {
+		for i := range candidates {
+			candidates[i].score = (candidates[i].score + titleSimilarity(album, recordings[i].albumTitle)) / 2
+		}
 	}
-	return recording, errNoRelease
+	return recordings, candidates, nil
 }
 
-func getAlbumInfo(client *gomusicbrainz.WS2Client, query string) (musicBrainzRelease, error) {
+// lookupTrackInfo returns a known recording without searching. Official
+// releases are preferred, the earliest one is chosen.
+func lookupTrackInfo(client *musicBrainz, id gomusicbrainz.MBID) (musicBrainzRecording, error) {
+	fmt.Println("\nLooking up track on musicbrainz: ")
+	json, err := client.getJSON("looking up the track", "/recording/"+string(id),
+		url.Values{"inc": {"artist-credits+releases+release-groups+media"}})
+	if err != nil {
+		return musicBrainzRecording{}, errors.Wrap(err, "LookupRecording failed")
+	}
+
+	value := gjson.ParseBytes(json)
+	releases := value.Get("releases").Array()
+	sort.SliceStable(releases, func(i, j int) bool {
+		di, dj := releases[i].Get("date").String(), releases[j].Get("date").String()
+		return di != "" && (dj == "" || di < dj)
+	})
+
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	for _, release := range releases {
+		score := 0.5
+		if release.Get("status").String() == "Official" {
+			score = 1
+		}
+		r, c := recordingReleases(value, []gjson.Result{release}, score)
+		recordings = append(recordings, r...)
+		candidates = append(candidates, c...)
+	}
+
+	if i, ok := (matcher{mode: matchYes}).choose("", candidates); ok {
+		return recordings[i], nil
+	}
+	return musicBrainzRecording{}, errNoRelease
+}
+
+func getAlbumInfo(client *musicBrainz, query string, hints releaseHints) (musicBrainzRelease, error) {
+	if hints.mbid != "" {
+		return lookupAlbumInfo(client, hints.mbid, hints)
+	}
+
 	var mbr musicBrainzRelease
-	artist, release := getArtistAlbumOrTrack(query)
+	t := parseVideoTitle(query, hints.author)
+	artist, release := t.artist, t.title
 	scanTo := map[string]*string{
 		"Artist":  &artist,
 		"Release": &release,
 	}
 
-	scanLines(scanTo)
+	p := hints.provided
+	if p.album != "" {
+		artist, release = strings.Join(p.artists, ", "), p.album
+		fmt.Printf("Artist: %s\nRelease: %s\n", artist, release)
+	} else if releaseMatcher.mode == matchAsk {
+		scanLines(scanTo)
+	}
 
 	query = fmt.Sprintf(`artist:"%s" AND %s`, strings.TrimSpace(artist), strings.TrimSpace(release))
+	if p.album != "" {
+		query = fmt.Sprintf(`artist:"%s" AND release:"%s"`, p.artists[0], p.album)
+		// the label is often a distributor, so it's optional
+		if p.label != "" {
+			query += fmt.Sprintf(` label:"%s"`, p.label)
+		}
+	}
 	fmt.Println("\nSearching release on musicbrainz: ")
-	resp, err := client.SearchRelease(query, 5, -1)
+	resp, err := client.searchRelease(query, 5)
 	if err != nil {
-		return mbr, errors.Wrap(err, "SearchRelease failed")
+		return mbr, err
 	}
 
+	var releases []musicBrainzRelease
+	var candidates []candidate
 	for _, release := range resp.Releases {
-		rec, _ := client.LookupRelease(release.Id(), "artist-credits", "labels", "discids", "recordings")
-		var label string
-		if len(rec.LabelInfos) > 0 {
-			label = rec.LabelInfos[0].Label.Name
-		}
-		fmt.Printf("Label: %s \nRelease: %s (%d)\n", label, release.Title, release.Date.Year())
-		for _, v := range rec.Mediums {
-			fmt.Println("Format: " + v.Format)
-			for _, t := range v.Tracks {
-				fmt.Printf("\t%s: %s - %s\n", t.Number, getArtists(t.Recording.ArtistCredit.NameCredits), t.Recording.Title)
-			}
-
-			if askForConfirmation("Choose release?") {
-				mbr.artist = artist
-				mbr.title = release.Title
-				mbr.year = strconv.Itoa(rec.Date.Year())
-				mbr.tracks = v.Tracks
-				return mbr, nil
-			}
-			fmt.Println()
+		r, c, err := releaseMedia(client, release.ID, resp.Scores[release], hints)
+		if err != nil {
+			return mbr, err
 		}
+		releases = append(releases, r...)
+		candidates = append(candidates, c...)
+	}
+
+	if i, ok := releaseMatcher.choose("Choose release?", candidates); ok {
+		return releases[i], nil
 	}
 	return mbr, errNoRelease
 }
 
-func getArtists(nc []gomusicbrainz.NameCredit) []string {
-	artists := make([]string, 0, len(nc))
-	for _, v := range nc {
-		artists = append(artists, v.Artist.Name)
+// lookupAlbumInfo returns a known release without searching. The medium that
+// fits the hints best is chosen.
+func lookupAlbumInfo(client *musicBrainz, id gomusicbrainz.MBID, hints releaseHints) (musicBrainzRelease, error) {
+	fmt.Println("\nLooking up release on musicbrainz: ")
+	releases, candidates, err := releaseMedia(client, id, 100, hints)
+	if err != nil {
+		return musicBrainzRelease{}, err
+	}
+
+	if i, ok := (matcher{mode: matchYes}).choose("", candidates); ok {
+		return releases[i], nil
+	}
+	return musicBrainzRelease{}, errNoRelease
+}
+
+// releaseMedia looks up a release and returns one candidate per medium.
+func releaseMedia(client *musicBrainz, id gomusicbrainz.MBID, searchScore int, hints releaseHints) ([]musicBrainzRelease, []candidate, error) {
+	inc := []string{"artist-credits", "labels", "discids", "recordings", "release-groups"}
+	if lookupWorks {
+		inc = append(inc, workIncludes...)
+	}
+	rec, rc, err := client.lookupRelease(id, inc...)
+	if err != nil {
+		return nil, nil, err
+	}
+	tags := xmlReleaseTags(rec, rc.release)
+
+	// every medium is a candidate, or the whole release if it was uploaded
+	// as one video
+	groups := make([][]*gomusicbrainz.Medium, 0, len(rec.Mediums))
+	if wholeRelease {
+		groups = append(groups, rec.Mediums)
+	} else {
+		for _, m := range rec.Mediums {
+			groups = append(groups, []*gomusicbrainz.Medium{m})
+		}
+	}
+
+	var releases []musicBrainzRelease
+	var candidates []candidate
+	for _, group := range groups {
+		var formats []string
+		var discs []int
+		var credits []artistCredit
+		var tracks string
+		merged := &gomusicbrainz.Medium{}
+		for _, m := range group {
+			formats = append(formats, m.Format)
+			merged.Tracks = append(merged.Tracks, m.Tracks...)
+			for j, t := range m.Tracks {
+				discs = append(discs, m.Position)
+				credits = append(credits, rc.tracks[t.ID])
+				number := t.Number
+				if len(rec.Mediums) > 1 {
+					number = fmt.Sprintf("%d-%s", m.Position, t.Number)
+				}
+				tracks += fmt.Sprintf("\t%s: %s - %s", number, rc.tracks[t.ID], t.Recording.Title)
+				if d, ok := trackDeviation(merged, len(merged.Tracks)-len(m.Tracks)+j, hints); ok {
+					tracks += fmt.Sprintf(" (%s, %+ds)", trackLength(t).Round(time.Second), int(d.Seconds()))
+				}
+				tracks += "\n"
+			}
+		}
+
+		desc := fmt.Sprintf("Label: %s \nRelease: %s (%s)\n", tags.label, rec.Title, tags.date)
+		if tags.originalDate != "" && dateYear(tags.originalDate) != dateYear(tags.date) {
+			desc += fmt.Sprintf("Originally released: %s\n", tags.originalDate)
+		}
+		desc += "Format: " + strings.Join(formats, " + ") + "\n" + tracks
+		if problems := mismatches(merged, hints); len(problems) > 0 {
+			desc += "MISMATCH: " + strings.Join(problems, ", ") + "\n"
+		}
+
+		mbr := musicBrainzRelease{
+			releaseTags: tags,
+			id:          rec.ID,
+			groupID:     rec.ReleaseGroup.ID,
+			title:       rec.Title,
+			tracks:      merged.Tracks,
+			credits:     credits,
+			works:       rc.works,
+		}
+		// single disc releases are tagged without a disc number
+		if len(rec.Mediums) > 1 {
+			mbr.discCount, mbr.discs = len(rec.Mediums), discs
+		}
+		releases = append(releases, mbr)
+		candidates = append(candidates, candidate{
+			desc:  desc,
+			score: scoreMedium(searchScore, merged, hints),
+		})
 	}
+	return releases, candidates, nil
+}
 
-	return artists
+// checkTrackTitles warns about titles from the video that don't look like the
+// tracks of the chosen release.
+func checkTrackTitles(titles []string, mbr musicBrainzRelease) {
+	if len(titles) == 0 {
+		return
+	}
+	if len(titles) != len(mbr.tracks) {
+		fmt.Printf("Warning: found %d timestamps but the release has %d tracks\n", len(titles), len(mbr.tracks))
+	}
+	for i := 0; i < len(titles) && i < len(mbr.tracks); i++ {
+		if titleSimilarity(titles[i], mbr.tracks[i].Recording.Title) < 0.5 {
+			fmt.Printf("Warning: track %d is %q in the video but %q on musicbrainz\n", i+1, titles[i], mbr.tracks[i].Recording.Title)
+		}
+	}
 }
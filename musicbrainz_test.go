@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseMBID(t *testing.T) {
+	defer func(host string) { musicBrainzHost = host }(musicBrainzHost)
+	musicBrainzHost = "mirror.local:5000"
+
+	const id = "0f7c8d3e-6b4a-4b8e-9a76-2c1f5b6e3d21"
+	for _, tc := range []struct {
+		entity, s string
+		ok        bool
+	}{
+		{"release", id, true},
+		{"release", "https://musicbrainz.org/release/" + id, true},
+		{"release", "beta.musicbrainz.org/release/" + id, true},
+		{"release", "http://mirror.local:5000/release/" + id, true},
+		{"recording", "http://mirror.local:5000/release/" + id, false},
+		{"release", "http://elsewhere.local/release/" + id, false},
+		{"release", "https://musicbrainz.org/release/", false},
+	} {
+		got, ok := parseMBID(tc.entity, tc.s)
+		if ok != tc.ok || ok && string(got) != id {
+			t.Errorf("parseMBID(%q, %q) = %q, %v", tc.entity, tc.s, got, ok)
+		}
+	}
+}
+
+func TestGetTrackInfoUnknownISRC(t *testing.T) {
+	defer fastRetries()()
+	defer func(m matcher) { releaseMatcher = m }(releaseMatcher)
+	releaseMatcher = matcher{mode: matchYes, threshold: 0.5}
+
+	var searched bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/isrc/"):
+			http.NotFound(w, r)
+		case r.URL.Path == "/recording/":
+			searched = true
+			fmt.Fprint(w, `{"recordings": [{"id": "rec", "score": 100, "title": "Song",
+				"artist-credit": [{"name": "Artist", "artist": {"id": "artist", "name": "Artist"}}],
+				"releases": [{"id": "rel", "title": "Album", "release-group": {"id": "group"},
+					"media": [{"position": 1, "track-count": 10, "track-offset": 2, "track": [{"id": "track"}]}]}]}]}`)
+		default:
+			t.Errorf("unexpected request %s", r.URL)
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	client := newMusicBrainz(srv.URL, "test", time.Second, 100, responseCache{})
+	rec, err := getTrackInfo(client, "Artist - Song", trackHints{provided: providedInfo{
+		title:   "Song",
+		artists: []string{"Artist"},
+		isrc:    "XX0000000000",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !searched {
+		t.Error("the track wasn't searched after the ISRC lookup failed")
+	}
+	if rec.recordingID != "rec" || rec.trackNum != 3 {
+		t.Errorf("got recording %q track %d", rec.recordingID, rec.trackNum)
+	}
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/pkg/errors"
+	"github.com/tidwall/gjson"
+)
+
+// these are variables so that they can be pointed to a local fixture server
+var youtubePlaylistURL = "https://www.youtube.com/playlist"
+var youtubeWatchURL = "https://www.youtube.com/watch"
+
+type playlistEntry struct {
+	id     string
+	title  string
+	length int // in seconds, 0 if unknown
+}
+
+type playlist struct {
+	id      string
+	title   string
+	entries []playlistEntry
+}
+
+// getPlaylistID returns the playlist id of a youtube playlist url. Urls of a
+// video in a playlist, like "watch?v=X&list=PL..." or mixes, stand for the
+// video only.
+func getPlaylistID(rawurl string) (string, bool) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", false
+	}
+	q := u.Query()
+	id := q.Get("list")
+	return id, id != "" && q.Get("v") == ""
+}
+
+func watchURL(videoID string) string {
+	u, _ := url.Parse(youtubeWatchURL)
+	q := u.Query()
+	q.Set("v", videoID)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+func getPlaylist(id string) (playlist, error) {
+	u, err := url.Parse(youtubePlaylistURL)
+	if err != nil {
+		return playlist{}, err
+	}
+	q := u.Query()
+	q.Set("list", id)
+	u.RawQuery = q.Encode()
+
+	var html []byte
+	err = netRetry.do("getting the playlist", func() error {
+		resp, err := http.Get(u.String())
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return newStatusError(resp)
+		}
+
+		html, err = ioutil.ReadAll(resp.Body)
+		return err
+	})
+	if err != nil {
+		return playlist{}, errors.Wrap(err, "couldn't get the playlist page")
+	}
+
+	return parsePlaylist(id, html)
+}
+
+// parsePlaylist extracts the playlist title and videos from the playlist page.
+// It understands the old table based markup as well as the ytInitialData json
+// that newer pages embed.
+func parsePlaylist(id string, html []byte) (playlist, error) {
+	pl := playlist{id: id}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return pl, err
+	}
+
+	pl.title = strings.TrimSpace(doc.Find(".pl-header-title").Text())
+	doc.Find("tr.pl-video").Each(func(i int, s *goquery.Selection) {
+		videoID, ok := s.Attr("data-video-id")
+		if !ok {
+			return
+		}
+		title, _ := s.Attr("data-title")
+		length, _ := durToSec(strings.TrimSpace(s.Find(".timestamp").Text()))
+		pl.entries = append(pl.entries, playlistEntry{id: videoID, title: title, length: length})
+	})
+
+	if len(pl.entries) > 0 {
+		return pl, nil
+	}
+
+	re := regexp.MustCompile(`(?s)ytInitialData"?\]?\s*=\s*(\{.+?\});\s*(?:</script>|\n|window)`)
+	matches := re.FindSubmatch(html)
+	if len(matches) < 2 {
+		return pl, errors.New("couldn't find any videos in the playlist")
+	}
+
+	data := gjson.ParseBytes(matches[1])
+	if title := data.Get("metadata.playlistMetadataRenderer.title").String(); title != "" {
+		pl.title = title
+	}
+
+	list := data.Get("contents.twoColumnBrowseResultsRenderer.tabs.0.tabRenderer.content.sectionListRenderer.contents.0.itemSectionRenderer.contents.0.playlistVideoListRenderer.contents")
+	list.ForEach(func(key, value gjson.Result) bool {
+		renderer := value.Get("playlistVideoRenderer")
+		videoID := renderer.Get("videoId").String()
+		if videoID == "" {
+			return true
+		}
+
+		title := renderer.Get("title.simpleText").String()
+		if title == "" {
+			title = renderer.Get("title.runs.0.text").String()
+		}
+
+		pl.entries = append(pl.entries, playlistEntry{
+			id:     videoID,
+			title:  title,
+			length: int(renderer.Get("lengthSeconds").Int()),
+		})
+		return true
+	})
+
+	if len(pl.entries) == 0 {
+		return pl, errors.New("couldn't find any videos in the playlist")
+	}
+	return pl, nil
+}
+
+// expandURL returns the video urls of a playlist url or the url itself if it
+// isn't one.
+func expandURL(rawurl string) ([]string, error) {
+	id, ok := getPlaylistID(rawurl)
+	if !ok {
+		return []string{rawurl}, nil
+	}
+
+	pl, err := getPlaylist(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "getPlaylist failed")
+	}
+
+	fmt.Printf("Playlist: %s (%d videos)\n", pl.title, len(pl.entries))
+	urls := make([]string, 0, len(pl.entries))
+	for _, e := range pl.entries {
+		urls = append(urls, watchURL(e.id))
+	}
+	return urls, nil
+}
+
+type trackMatch struct {
+	track int
+	entry int
+	score float64
+}
+
+// matchPlaylistTracks maps the entries of a playlist to the tracks of a
+// medium. Every pair is scored by its position, title similarity and duration,
+// the best pairs are taken first. The returned slice holds the index of the
+// entry for every track, or -1 if no entry fits.
+func matchPlaylistTracks(entries []playlistEntry, tracks []*gomusicbrainz.Track) []int {
+	var matches []trackMatch
+	for i, t := range tracks {
+		for j, e := range entries {
+			// order
+			dist := math.Abs(float64(i - j))
+			score := 0.3 * math.Max(0, 1-dist/3)
+
+			// title, with and without a leading "artist -"
+			sim := titleSimilarity(e.title, t.Recording.Title)
+			if title := parseVideoTitle(e.title, "").title; title != "" {
+				sim = math.Max(sim, titleSimilarity(title, t.Recording.Title))
+			}
+			score += 0.5 * sim
+
+			// duration
+			length := t.Length
+			if length == 0 {
+				length = t.Recording.Length
+			}
+			if e.length > 0 && length > 0 {
+				diff := math.Abs(float64(e.length) - float64(length)/1000)
+				score += 0.2 * math.Max(0, 1-diff/30)
+			}
+
+			matches = append(matches, trackMatch{track: i, entry: j, score: score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+
+	result := make([]int, len(tracks))
+	for i := range result {
+		result[i] = -1
+	}
+	used := make(map[int]bool)
+	for _, m := range matches {
+		if m.score < 0.4 || result[m.track] != -1 || used[m.entry] {
+			continue
+		}
+		result[m.track] = m.entry
+		used[m.entry] = true
+	}
+	return result
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const tablePlaylistHTML = `<html><body>
+<h1 class="pl-header-title">
+	Artist - Album
+</h1>
+<table>
+<tr class="pl-video" data-video-id="aaaaaaaaaaa" data-title="Artist - First"><td><div class="timestamp"><span>3:05</span></div></td></tr>
+<tr class="pl-video" data-video-id="bbbbbbbbbbb" data-title="Artist - Second"><td><div class="timestamp"><span>1:02:03</span></div></td></tr>
+<tr class="pl-video" data-title="deleted video"></tr>
+</table>
+</body></html>`
+
+const initialDataPlaylistHTML = `<html><body><script>
+var ytInitialData = {"metadata":{"playlistMetadataRenderer":{"title":"Artist - Album"}},
+"contents":{"twoColumnBrowseResultsRenderer":{"tabs":[{"tabRenderer":{"content":{"sectionListRenderer":{"contents":[{"itemSectionRenderer":{"contents":[{"playlistVideoListRenderer":{"contents":[
+{"playlistVideoRenderer":{"videoId":"aaaaaaaaaaa","title":{"simpleText":"Artist - First"},"lengthSeconds":"185"}},
+{"playlistVideoRenderer":{"videoId":"bbbbbbbbbbb","title":{"runs":[{"text":"Artist - Second"}]},"lengthSeconds":"3723"}},
+{"continuationItemRenderer":{}}
+]}}]}}]}}}}]}}};
+</script></body></html>`
+
+var wantPlaylistEntries = []playlistEntry{
+	{id: "aaaaaaaaaaa", title: "Artist - First", length: 185},
+	{id: "bbbbbbbbbbb", title: "Artist - Second", length: 3723},
+}
+
+func TestParsePlaylist(t *testing.T) {
+	for name, html := range map[string]string{
+		"table":         tablePlaylistHTML,
+		"ytInitialData": initialDataPlaylistHTML,
+	} {
+		pl, err := parsePlaylist("PL1", []byte(html))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if pl.title != "Artist - Album" {
+			t.Errorf("%s: title = %q", name, pl.title)
+		}
+		if !reflect.DeepEqual(pl.entries, wantPlaylistEntries) {
+			t.Errorf("%s: entries = %+v", name, pl.entries)
+		}
+	}
+
+	if _, err := parsePlaylist("PL1", []byte("<html></html>")); err == nil {
+		t.Error("a page without videos was accepted")
+	}
+}
+
+func TestExpandURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list") != "PL1" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, initialDataPlaylistHTML)
+	}))
+	defer srv.Close()
+
+	defer func(playlistURL, watch string) {
+		youtubePlaylistURL, youtubeWatchURL = playlistURL, watch
+	}(youtubePlaylistURL, youtubeWatchURL)
+	youtubePlaylistURL = srv.URL + "/playlist"
+	youtubeWatchURL = srv.URL + "/watch"
+
+	urls, err := expandURL(srv.URL + "/playlist?list=PL1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{srv.URL + "/watch?v=aaaaaaaaaaa", srv.URL + "/watch?v=bbbbbbbbbbb"}
+	if !reflect.DeepEqual(urls, want) {
+		t.Errorf("urls = %v, want %v", urls, want)
+	}
+
+	for _, video := range []string{
+		"https://www.youtube.com/watch?v=ccccccccccc",
+		"https://www.youtube.com/watch?v=ccccccccccc&list=PL1&index=3",
+		"https://www.youtube.com/watch?v=ccccccccccc&list=RDccccccccccc",
+	} {
+		urls, err = expandURL(video)
+		if err != nil || len(urls) != 1 || urls[0] != video {
+			t.Errorf("the video url %s was expanded to %v, %v", video, urls, err)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strings"
+)
+
+// providedInfo is the metadata of the descriptions youtube generates for the
+// uploads of "Artist - Topic" channels:
+//
+//	Provided to YouTube by Label
+//
+//	Track · Artist · Other Artist
+//
+//	Album
+//
+//	℗ 2020 Label
+//
+//	Released on: 2020-03-20
+type providedInfo struct {
+	label   string
+	title   string
+	artists []string
+	album   string
+	isrc    string
+}
+
+const providedPrefix = "Provided to YouTube by"
+
+// parseProvidedDescription returns the metadata of an auto generated
+// description. ok is false and the info empty if the description wasn't
+// generated or lacks the title or artists.
+func parseProvidedDescription(desc string) (info providedInfo, ok bool) {
+	var lines []string
+	for _, l := range strings.Split(desc, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+
+	start := -1
+	for i, l := range lines {
+		if strings.HasPrefix(l, providedPrefix) {
+			start = i
+			break
+		}
+	}
+	if start < 0 || start+1 >= len(lines) {
+		return providedInfo{}, false
+	}
+
+	info.label = strings.TrimSpace(strings.TrimPrefix(lines[start], providedPrefix))
+
+	parts := strings.Split(lines[start+1], "·")
+	if len(parts) < 2 {
+		return providedInfo{}, false
+	}
+	info.title = strings.TrimSpace(parts[0])
+	for _, a := range parts[1:] {
+		if a = strings.TrimSpace(a); a != "" {
+			info.artists = append(info.artists, a)
+		}
+	}
+	if info.title == "" || len(info.artists) == 0 {
+		return providedInfo{}, false
+	}
+
+	for i, l := range lines[start+2:] {
+		switch {
+		case i == 0 && !strings.HasPrefix(l, "℗") && !strings.HasPrefix(l, "Released on:"):
+			info.album = l
+		case strings.HasPrefix(strings.ToUpper(l), "ISRC:"):
+			info.isrc = strings.ToUpper(strings.TrimSpace(l[len("ISRC:"):]))
+		}
+	}
+	return info, true
+}
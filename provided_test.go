@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseProvidedDescription(t *testing.T) {
+	info, ok := parseProvidedDescription(`Provided to YouTube by Label
+
+Track · Artist · Other Artist
+
+Album
+
+℗ 2020 Label
+
+Released on: 2020-03-20
+
+ISRC: usabc2000001
+
+Auto-generated by YouTube.`)
+	want := providedInfo{label: "Label", title: "Track", artists: []string{"Artist", "Other Artist"}, album: "Album", isrc: "USABC2000001"}
+	if !ok || !reflect.DeepEqual(info, want) {
+		t.Errorf("got %+v, %v, want %+v", info, ok, want)
+	}
+
+	for _, desc := range []string{
+		"",
+		"Just a video",
+		"Provided to YouTube by Label",
+		"Provided to YouTube by Label\n\nTrack without artists",
+		"Provided to YouTube by Label\n\nSome Track · \n\nAlbum",
+		"Provided to YouTube by Label\n\n · Artist\n\nAlbum",
+	} {
+		if info, ok := parseProvidedDescription(desc); ok || !reflect.DeepEqual(info, providedInfo{}) {
+			t.Errorf("%q: got %+v, %v", desc, info, ok)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+	"github.com/pkg/errors"
+)
+
+// metadataProvider finds the release or recording a video contains. All
+// providers return the same model, providers that don't know musicbrainz
+// leave the musicbrainz ids empty.
+type metadataProvider interface {
+	name() string
+	findRelease(query string, hints releaseHints) (musicBrainzRelease, error)
+	findTrack(query string, hints trackHints) (musicBrainzRecording, error)
+}
+
+// providerChain asks one provider after the other until one finds a match.
+type providerChain []metadataProvider
+
+func (c providerChain) name() string {
+	names := make([]string, 0, len(c))
+	for _, p := range c {
+		names = append(names, p.name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (c providerChain) findRelease(query string, hints releaseHints) (musicBrainzRelease, error) {
+	err := errNoRelease
+	for i, p := range c {
+		if i > 0 {
+			fmt.Printf("\nTrying %s instead:\n", p.name())
+		}
+		var mbr musicBrainzRelease
+		if mbr, err = p.findRelease(query, hints); err == nil {
+			return mbr, nil
+		}
+		fmt.Printf("%s: %v\n", p.name(), err)
+	}
+	return musicBrainzRelease{}, err
+}
+
+func (c providerChain) findTrack(query string, hints trackHints) (musicBrainzRecording, error) {
+	err := errNoRelease
+	for i, p := range c {
+		if i > 0 {
+			fmt.Printf("\nTrying %s instead:\n", p.name())
+		}
+		var mbr musicBrainzRecording
+		if mbr, err = p.findTrack(query, hints); err == nil {
+			return mbr, nil
+		}
+		fmt.Printf("%s: %v\n", p.name(), err)
+	}
+	return musicBrainzRecording{}, err
+}
+
+// parseProviders returns the chain of the comma separated provider names.
+func parseProviders(list string, available map[string]metadataProvider) (providerChain, error) {
+	var chain providerChain
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name == "" {
+			continue
+		}
+		p, ok := available[name]
+		if !ok {
+			return nil, errors.Errorf("unknown metadata provider %q", name)
+		}
+		chain = append(chain, p)
+	}
+	if len(chain) == 0 {
+		return nil, errors.New("no metadata provider given")
+	}
+	return chain, nil
+}
+
+// musicBrainzProvider looks up releases and recordings on musicbrainz and
+// adds their genres and works.
+type musicBrainzProvider struct {
+	client *musicBrainz
+}
+
+func (p musicBrainzProvider) name() string {
+	return "musicbrainz"
+}
+
+func (p musicBrainzProvider) findRelease(query string, hints releaseHints) (musicBrainzRelease, error) {
+	mbr, err := getAlbumInfo(p.client, query, hints)
+	if err != nil {
+		return mbr, err
+	}
+	mbr.genres = getGenres(p.client, mbr.id, mbr.groupID, mbr.albumCredit.ids(), hints.keywords)
+	return mbr, nil
+}
+
+func (p musicBrainzProvider) findTrack(query string, hints trackHints) (musicBrainzRecording, error) {
+	mbr, err := getTrackInfo(p.client, query, hints)
+	if err != nil {
+		return mbr, err
+	}
+	mbr.genres = getGenres(p.client, mbr.releaseID, mbr.groupID, mbr.albumCredit.ids(), hints.keywords)
+	mbr.work = getRecordingWork(p.client, mbr.recordingID)
+	return mbr, nil
+}
+
+// plainTrack is a track of a provider that doesn't know musicbrainz.
+type plainTrack struct {
+	title  string
+	credit artistCredit
+	length time.Duration
+	disc   int // 0 if unknown
+}
+
+// plainRelease turns the tracks of a provider that doesn't know musicbrainz
+// into a release.
+func plainRelease(tags releaseTags, title string, tracks []plainTrack) musicBrainzRelease {
+	r := musicBrainzRelease{releaseTags: tags, title: title}
+	for i, t := range tracks {
+		disc := t.disc
+		if disc <= 0 {
+			disc = 1
+		}
+		if disc > r.discCount {
+			r.discCount = disc
+		}
+
+		length := int(t.length / time.Millisecond)
+		r.tracks = append(r.tracks, &gomusicbrainz.Track{
+			Position:  i + 1,
+			Number:    fmt.Sprint(i + 1),
+			Length:    length,
+			Recording: gomusicbrainz.Recording{Title: t.title, Length: length},
+		})
+		r.credits = append(r.credits, t.credit)
+		r.discs = append(r.discs, disc)
+	}
+	// single disc releases are tagged without a disc number
+	if r.discCount <= 1 {
+		r.discCount, r.discs = 0, nil
+	}
+	return r
+}
+
+// plainMedia returns one candidate per medium of a release from plainRelease,
+// or a single one for the whole release if wholeRelease is set. The search
+// score is 0-100 like the one of musicbrainz.
+func plainMedia(provider string, r musicBrainzRelease, searchScore int, hints releaseHints) ([]musicBrainzRelease, []candidate) {
+	media := []musicBrainzRelease{r}
+	if !wholeRelease && r.discCount > 1 {
+		media = nil
+		for disc := 1; disc <= r.discCount; disc++ {
+			m := r
+			m.tracks, m.credits, m.discs = nil, nil, nil
+			for i, d := range r.discs {
+				if d == disc {
+					m.tracks = append(m.tracks, r.tracks[i])
+					m.credits = append(m.credits, r.credits[i])
+					m.discs = append(m.discs, d)
+				}
+			}
+			if len(m.tracks) > 0 {
+				media = append(media, m)
+			}
+		}
+	}
+
+	var candidates []candidate
+	for _, m := range media {
+		medium := &gomusicbrainz.Medium{Tracks: m.tracks}
+		desc := fmt.Sprintf("Provider: %s\nLabel: %s \nRelease: %s - %s (%s)\n", provider, m.label, m.albumCredit, m.title, m.date)
+		for i, t := range m.tracks {
+			rec := m.recording(i)
+			number := fmt.Sprint(rec.trackNum)
+			if rec.cdNum >= 0 {
+				number = fmt.Sprintf("%d-%d", rec.cdNum, rec.trackNum)
+			}
+			desc += fmt.Sprintf("\t%s: %s - %s (%s)\n", number, rec.trackCredit, rec.trackTitle, trackLength(t))
+		}
+		if problems := mismatches(medium, hints); len(problems) > 0 {
+			desc += "MISMATCH: " + strings.Join(problems, ", ") + "\n"
+		}
+		candidates = append(candidates, candidate{desc: desc, score: scoreMedium(searchScore, medium, hints)})
+	}
+	return media, candidates
+}
+
+// plainTracks returns a candidate for every track of the release that could
+// be the video.
+func plainTracks(provider string, r musicBrainzRelease, searchScore int, hints trackHints) ([]musicBrainzRecording, []candidate) {
+	var recordings []musicBrainzRecording
+	var candidates []candidate
+	for i := range r.tracks {
+		rec := r.recording(i)
+		length := trackLength(r.tracks[i])
+		recordings = append(recordings, rec)
+		candidates = append(candidates, candidate{
+			desc: fmt.Sprintf("Provider: %s\nRelease: %s (%s)\nTrack: %.2d/%.2d %s - %s (%s)\n",
+				provider, rec.albumTitle, rec.date, rec.trackNum, rec.trackCount, rec.trackCredit, rec.trackTitle, length),
+			score: scoreRecording(searchScore, rec.trackTitle, length, hints),
+		})
+	}
+	return recordings, candidates
+}
+
+// queryTitle returns the artist and title a provider searches for. Auto
+// generated descriptions are preferred over the video title.
+func queryTitle(query, author string, provided providedInfo, album bool) (string, string) {
+	t := parseVideoTitle(query, author)
+	if len(provided.artists) == 0 {
+		return t.artist, t.title
+	}
+	switch {
+	case album && provided.album != "":
+		return provided.artists[0], provided.album
+	case !album && provided.title != "":
+		return provided.artists[0], provided.title
+	}
+	return t.artist, t.title
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// stubProvider finds the release and track with its title or fails with err.
+type stubProvider struct {
+	title string
+	err   error
+	asked *[]string
+}
+
+func (p stubProvider) name() string {
+	return p.title
+}
+
+func (p stubProvider) findRelease(query string, hints releaseHints) (musicBrainzRelease, error) {
+	*p.asked = append(*p.asked, p.title)
+	return musicBrainzRelease{title: p.title}, p.err
+}
+
+func (p stubProvider) findTrack(query string, hints trackHints) (musicBrainzRecording, error) {
+	*p.asked = append(*p.asked, p.title)
+	return musicBrainzRecording{trackTitle: p.title}, p.err
+}
+
+func TestProviderChain(t *testing.T) {
+	var asked []string
+	errDown := errors.New("down")
+	available := map[string]metadataProvider{
+		"down":    stubProvider{"down", errDown, &asked},
+		"missing": stubProvider{"missing", errNoRelease, &asked},
+		"first":   stubProvider{"first", nil, &asked},
+		"second":  stubProvider{"second", nil, &asked},
+	}
+
+	for _, tc := range []struct {
+		list  string
+		found string
+		err   error
+		asked []string
+	}{
+		{"first,second", "first", nil, []string{"first"}},
+		{"down, Missing ,second", "second", nil, []string{"down", "missing", "second"}},
+		{"missing,down", "", errDown, []string{"missing", "down"}},
+	} {
+		chain, err := parseProviders(tc.list, available)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		asked = nil
+		r, err := chain.findRelease("Artist - Album", releaseHints{})
+		if r.title != tc.found || err != tc.err || !reflect.DeepEqual(asked, tc.asked) {
+			t.Errorf("%s: found release %q, %v after asking %v", tc.list, r.title, err, asked)
+		}
+
+		asked = nil
+		rec, err := chain.findTrack("Artist - Song", trackHints{})
+		if rec.trackTitle != tc.found || err != tc.err || !reflect.DeepEqual(asked, tc.asked) {
+			t.Errorf("%s: found track %q, %v after asking %v", tc.list, rec.trackTitle, err, asked)
+		}
+	}
+
+	for _, list := range []string{"", " , ", "first,unknown"} {
+		if _, err := parseProviders(list, available); err == nil {
+			t.Errorf("parseProviders(%q) succeeded", list)
+		}
+	}
+}
+
+func TestQueryTitle(t *testing.T) {
+	partial, _ := parseProvidedDescription("Provided to YouTube by Label\n\nSome Track · \n\nAlbum")
+	provided := providedInfo{title: "Track", artists: []string{"Artist", "Guest"}, album: "Album"}
+
+	for _, tc := range []struct {
+		provided      providedInfo
+		album         bool
+		artist, title string
+	}{
+		{providedInfo{}, true, "Band", "Song"},
+		{partial, true, "Band", "Song"},
+		{partial, false, "Band", "Song"},
+		{provided, true, "Artist", "Album"},
+		{provided, false, "Artist", "Track"},
+	} {
+		artist, title := queryTitle("Band - Song", "", tc.provided, tc.album)
+		if artist != tc.artist || title != tc.title {
+			t.Errorf("queryTitle(%+v, %v) = %q, %q, want %q, %q", tc.provided, tc.album, artist, title, tc.artist, tc.title)
+		}
+	}
+}
+
+func TestPlainMediaDiscs(t *testing.T) {
+	defer func(whole, folders bool) { wholeRelease, discFolders = whole, folders }(wholeRelease, discFolders)
+	wholeRelease, discFolders = false, false
+
+	credit := manualCredit("Artist")
+	r := plainRelease(releaseTags{albumCredit: credit}, "Album", []plainTrack{
+		{title: "First", credit: credit, disc: 1},
+		{title: "Second", credit: credit, disc: 1},
+		{title: "Third", credit: credit, disc: 2},
+	})
+	media, _ := plainMedia("manual", r, 100, releaseHints{})
+	if len(media) != 2 {
+		t.Fatalf("%d media, want 2", len(media))
+	}
+
+	// the second disc from its own video
+	rec := media[1].recording(0)
+	if rec.cdNum != 2 || rec.discCount != 2 || rec.trackNum != 1 || rec.trackCount != 1 {
+		t.Errorf("disc %d/%d, track %d/%d", rec.cdNum, rec.discCount, rec.trackNum, rec.trackCount)
+	}
+	if path := recordPath(rec, "dir"); path != filepath.Join("dir", "2-01 Artist - Third.mp3") {
+		t.Errorf("the track is written to %s", path)
+	}
+	if first, second := cuePath(media[0], "dir"), cuePath(media[1], "dir"); first == second {
+		t.Errorf("both discs are written to %s", first)
+	}
+
+	wholeRelease = true
+	media, _ = plainMedia("manual", r, 100, releaseHints{})
+	if len(media) != 1 || cuePath(media[0], "dir") != filepath.Join("dir", "Album.cue") {
+		t.Errorf("the whole release is split into %d media", len(media))
+	}
+
+	single := plainRelease(releaseTags{}, "Single", []plainTrack{{title: "A"}, {title: "B"}})
+	if rec := single.recording(1); rec.cdNum != -1 || rec.discCount != 0 || rec.trackNum != 2 {
+		t.Errorf("a single disc release is tagged as disc %d/%d", rec.cdNum, rec.discCount)
+	}
+}
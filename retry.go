@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// statusError is returned if a server answers with an unexpected status code.
+type statusError struct {
+	code       int
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return "server returned unexpected status: " + e.status
+}
+
+func newStatusError(resp *http.Response) *statusError {
+	e := &statusError{code: resp.StatusCode, status: resp.Status}
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if sec, err := strconv.Atoi(v); err == nil {
+			e.retryAfter = time.Duration(sec) * time.Second
+		} else if t, err := http.ParseTime(v); err == nil {
+			e.retryAfter = time.Until(t)
+		}
+	}
+	return e
+}
+
+// writeError is returned if the destination of a download fails, e.g. because
+// ffmpeg exited. Downloading again doesn't help.
+type writeError struct {
+	err error
+}
+
+func (e *writeError) Error() string {
+	return "couldn't write the download: " + e.err.Error()
+}
+
+// ytdl only reports the status code in its error messages
+var ytdlStatusRe = regexp.MustCompile(`(?i)status code:? (\d{3})`)
+
+type retryPolicy struct {
+	attempts    int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	jitter      float64 // fraction of the delay that is randomized
+	statusCodes map[int]bool
+}
+
+// netRetry is used for every network operation.
+var netRetry = retryPolicy{
+	attempts:    5,
+	backoff:     time.Second,
+	maxBackoff:  time.Minute,
+	jitter:      0.2,
+	statusCodes: map[int]bool{429: true, 500: true, 502: true, 503: true, 504: true},
+}
+
+func parseStatusCodes(list string) (map[int]bool, error) {
+	codes := make(map[int]bool)
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v == "" {
+			continue
+		}
+		code, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, errors.Errorf("invalid status code %q", v)
+		}
+		codes[code] = true
+	}
+	return codes, nil
+}
+
+// retryable reports whether err is worth another attempt and how long the
+// server asked us to wait.
+func (p retryPolicy) retryable(err error) (bool, time.Duration) {
+	cause := errors.Cause(err)
+
+	if _, ok := cause.(*writeError); ok {
+		return false, 0
+	}
+	if e, ok := cause.(*statusError); ok {
+		return p.statusCodes[e.code], e.retryAfter
+	}
+	if e, ok := cause.(net.Error); ok && e.Timeout() {
+		return true, 0
+	}
+	if cause == io.ErrUnexpectedEOF {
+		return true, 0
+	}
+
+	msg := cause.Error()
+	if m := ytdlStatusRe.FindStringSubmatch(msg); m != nil {
+		code, _ := strconv.Atoi(m[1])
+		return p.statusCodes[code], 0
+	}
+
+	for _, s := range []string{
+		"connection reset",
+		"broken pipe",
+		"unexpected EOF",
+		"timeout",
+		// musicbrainz answers with an error document if we are rate limited
+		"but have <error>",
+	} {
+		if strings.Contains(msg, s) {
+			return true, 0
+		}
+	}
+	return false, 0
+}
+
+// delay returns the backoff before the given attempt.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if p.jitter > 0 {
+		d += time.Duration((rand.Float64()*2 - 1) * p.jitter * float64(d))
+	}
+	return d
+}
+
+// do calls fn until it succeeds, fails with an error that isn't retryable or
+// the attempts are used up.
+func (p retryPolicy) do(what string, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+
+		ok, wait := p.retryable(err)
+		if !ok || attempt >= p.attempts {
+			return err
+		}
+		if wait <= 0 {
+			wait = p.delay(attempt)
+		}
+
+		fmt.Printf("%s failed: %v (retrying in %s)\n", what, err, wait.Round(time.Millisecond))
+		time.Sleep(wait)
+	}
+}
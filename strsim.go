@@ -0,0 +1,72 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// normalizeTitle lowercases s and reduces it to letters, digits and single
+// spaces, so that titles can be compared regardless of punctuation.
+func normalizeTitle(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			b.WriteRune(r)
+			space = false
+		} else {
+			space = true
+		}
+	}
+	return b.String()
+}
+
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// titleSimilarity returns a value between 0 and 1 describing how alike two
+// titles are. A title that is fully contained in the other one counts as a
+// match, because video titles usually carry extra noise like the artist.
+func titleSimilarity(a, b string) float64 {
+	a, b = normalizeTitle(a), normalizeTitle(b)
+	if a == "" || b == "" {
+		return 0
+	}
+	if a == b || strings.Contains(" "+a+" ", " "+b+" ") || strings.Contains(" "+b+" ", " "+a+" ") {
+		return 1
+	}
+
+	ra, rb := []rune(a), []rune(b)
+	l := len(ra)
+	if len(rb) > l {
+		l = len(rb)
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(l)
+}
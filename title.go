@@ -0,0 +1,107 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// videoTitle is what a video title tells about the music in it.
+type videoTitle struct {
+	artist   string
+	title    string   // the release or track title
+	featured []string // guest artists
+}
+
+var (
+	// separates the artist from the title, a plain hyphen needs a space next
+	// to it so names like Jay-Z stay intact
+	titleSepRe = regexp.MustCompile(`\s*[–—|]\s*|\s+-\s*|\s*-\s+|\s+~\s+`)
+
+	noiseWords = `full\s+(?:album|ep)|(?:full\s+)?album\s+stream|(?:official\s+)?(?:music\s+|lyrics?\s+)?(?:video|audio|visuali[sz]er)` +
+		`|official|lyrics?|with\s+lyrics|hd|hq|4k|1080p|720p|(?:\d{4}\s+)?remaster(?:ed)?(?:\s+\d{4})?|explicit|audio|m/?v`
+	// years are only noise next to a title, "1989" alone is an album
+	noiseOrYear = noiseWords + `|(?:19|20)\d{2}`
+
+	// noise in brackets, e.g. "(Full Album)" or "[HQ]"
+	bracketNoiseRe = regexp.MustCompile(`(?i)\s*[(\[{]\s*(?:` + noiseOrYear + `)(?:\s*[,/&+]?\s*(?:` + noiseOrYear + `))*\s*[)\]}]`)
+	// noise at the end of a title, e.g. "Album Full Album HD"
+	trailingNoiseRe = regexp.MustCompile(`(?i)(?:\s+|^)(?:` + noiseOrYear + `)\s*$`)
+	onlyNoiseRe     = regexp.MustCompile(`(?i)^\s*(?:(?:` + noiseWords + `)\s*)+$`)
+
+	featRe = regexp.MustCompile(`(?i)\s*[(\[]?\s*\b(?:feat\.?|ft\.?|featuring)\s+([^)\]]+?)\s*(?:[)\]]|$)`)
+	// separates several guests
+	guestSepRe = regexp.MustCompile(`(?i)\s*(?:,|&|\band\b)\s*`)
+
+	authorNoiseRe = regexp.MustCompile(`(?i)\s*(?:-\s*topic|vevo|official(?:\s+channel)?)\s*$`)
+)
+
+// authorArtist returns the artist a channel name stands for, e.g. "Artist" for
+// the auto generated "Artist - Topic" channels.
+func authorArtist(author string) string {
+	return strings.TrimSpace(authorNoiseRe.ReplaceAllString(author, ""))
+}
+
+// stripNoise removes everything from s that doesn't belong to a title.
+func stripNoise(s string) string {
+	s = bracketNoiseRe.ReplaceAllString(s, "")
+	for {
+		stripped := trailingNoiseRe.ReplaceAllString(s, "")
+		// keep titles that consist of noise only, like the album "1989"
+		if stripped == s || strings.TrimSpace(stripped) == "" {
+			break
+		}
+		s = stripped
+	}
+	return strings.TrimSpace(s)
+}
+
+// splitFeatured removes the guests from s and returns them separately.
+func splitFeatured(s string) (string, []string) {
+	var guests []string
+	for _, m := range featRe.FindAllStringSubmatch(s, -1) {
+		for _, g := range guestSepRe.Split(m[1], -1) {
+			if g = strings.TrimSpace(g); g != "" {
+				guests = append(guests, g)
+			}
+		}
+	}
+	return strings.TrimSpace(featRe.ReplaceAllString(s, "")), guests
+}
+
+// parseVideoTitle splits a video title into artist and title. author is the
+// channel of the video, it is used if the title contains no artist and to
+// detect titles in "Title - Artist" order. It may be empty.
+func parseVideoTitle(s, author string) videoTitle {
+	var parts []string
+	for _, p := range titleSepRe.Split(bracketNoiseRe.ReplaceAllString(s, ""), -1) {
+		if p = strings.TrimSpace(p); p != "" && !onlyNoiseRe.MatchString(p) {
+			parts = append(parts, p)
+		}
+	}
+	if len(parts) == 0 {
+		parts = []string{strings.TrimSpace(s)}
+	}
+
+	var t videoTitle
+	authorHint := authorArtist(author)
+	switch {
+	case len(parts) == 1:
+		t.artist, t.title = authorHint, parts[0]
+	case authorHint != "" && titleSimilarity(authorHint, parts[len(parts)-1]) >= 0.8 &&
+		titleSimilarity(authorHint, parts[0]) < 0.8:
+		t.artist = parts[len(parts)-1]
+		t.title = strings.Join(parts[:len(parts)-1], " - ")
+	default:
+		t.artist = parts[0]
+		t.title = strings.Join(parts[1:], " - ")
+	}
+
+	var guests []string
+	t.artist, t.featured = splitFeatured(t.artist)
+	t.title, guests = splitFeatured(t.title)
+	t.featured = append(t.featured, guests...)
+
+	t.artist = stripNoise(t.artist)
+	t.title = stripNoise(t.title)
+	return t
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVideoTitle(t *testing.T) {
+	for _, tc := range []struct {
+		title, author string
+		want          videoTitle
+	}{
+		{"Jay-Z - The Blueprint (Full Album) [HQ]", "", videoTitle{artist: "Jay-Z", title: "The Blueprint"}},
+		{"Artist – Album", "", videoTitle{artist: "Artist", title: "Album"}},
+		{"Artist — Album (Official Audio)", "", videoTitle{artist: "Artist", title: "Album"}},
+		{"Artist | Album | Full Album", "", videoTitle{artist: "Artist", title: "Album"}},
+		{"Artist - Song feat. Guest", "", videoTitle{artist: "Artist", title: "Song", featured: []string{"Guest"}}},
+		{"Artist ft. Guest A & Guest B - Song", "", videoTitle{artist: "Artist", title: "Song", featured: []string{"Guest A", "Guest B"}}},
+		{"Artist - Song (featuring Guest)", "", videoTitle{artist: "Artist", title: "Song", featured: []string{"Guest"}}},
+		{"Song", "Artist - Topic", videoTitle{artist: "Artist", title: "Song"}},
+		{"Song - Artist", "Artist - Topic", videoTitle{artist: "Artist", title: "Song"}},
+		{"Song", "ArtistVEVO", videoTitle{artist: "Artist", title: "Song"}},
+		{"Artist - Album 1994", "", videoTitle{artist: "Artist", title: "Album"}},
+		{"Artist - Album (1994) [Remastered 2010]", "", videoTitle{artist: "Artist", title: "Album"}},
+		{"Taylor Swift - 1989", "", videoTitle{artist: "Taylor Swift", title: "1989"}},
+		{"1989 (Full Album)", "Taylor Swift - Topic", videoTitle{artist: "Taylor Swift", title: "1989"}},
+	} {
+		if got := parseVideoTitle(tc.title, tc.author); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("parseVideoTitle(%q, %q) = %+v, want %+v", tc.title, tc.author, got, tc.want)
+		}
+	}
+}
+
+func TestStripNoise(t *testing.T) {
+	for s, want := range map[string]string{
+		"Album Full Album HD":       "Album",
+		"Album (Official Video)":    "Album",
+		"Album [2011 Remaster]":     "Album",
+		"1989":                      "1989",
+		"Live at Wembley (Live) HQ": "Live at Wembley (Live)",
+	} {
+		if got := stripNoise(s); got != want {
+			t.Errorf("stripNoise(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
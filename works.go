@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/url"
+
+	"github.com/michiwend/gomusicbrainz"
+)
+
+// lookupWorks enables the lookup of the works the recordings perform.
+var lookupWorks bool
+
+// workCredits describe the work a recording performs, e.g. the movement of a
+// symphony, and who wrote it.
+type workCredits struct {
+	workID         string
+	work           string
+	movement       string // empty unless the work is part of a larger work
+	movementNumber int64
+	composers      []string
+	lyricists      []string
+}
+
+// workIncludes are the includes of a release lookup that add the works of the
+// recordings, see xmlWork.
+var workIncludes = []string{"recording-level-rels", "work-rels", "work-level-rels", "artist-rels"}
+
+// xmlRelation is a relation of a recording in a release lookup with
+// workIncludes. Only the relations to works have a work.
+type xmlRelation struct {
+	Type string `xml:"type,attr"`
+	Work *struct {
+		ID        string `xml:"id,attr"`
+		Title     string `xml:"title"`
+		Relations []struct {
+			Type         string `xml:"type,attr"`
+			Direction    string `xml:"direction"`
+			OrderingKey  int64  `xml:"ordering-key"`
+			TargetCredit string `xml:"target-credit"`
+			Artist       string `xml:"artist>name"`
+			Work         string `xml:"work>title"`
+		} `xml:"relation-list>relation"`
+	} `xml:"work"`
+}
+
+// xmlWork returns the works the relations of a recording perform. Medleys
+// perform several works, the first one is used for the title and all of them
+// for the credits.
+func xmlWork(relations []xmlRelation) workCredits {
+	var w workCredits
+	for _, rel := range relations {
+		if rel.Work == nil || rel.Type != "performance" {
+			continue
+		}
+
+		first := w.workID == ""
+		if first {
+			w.workID = rel.Work.ID
+			w.work = rel.Work.Title
+		}
+
+		for _, r := range rel.Work.Relations {
+			name := r.TargetCredit
+			if name == "" {
+				name = r.Artist
+			}
+
+			switch r.Type {
+			// songs are often credited to writers instead of composers
+			case "composer", "writer":
+				w.composers = appendMissing(w.composers, name)
+			case "lyricist":
+				w.lyricists = appendMissing(w.lyricists, name)
+			case "parts":
+				// a backward part relation points to the larger work
+				if first && r.Direction == "backward" && w.movement == "" {
+					w.movement = w.work
+					w.work = r.Work
+					w.movementNumber = r.OrderingKey
+				}
+			}
+		}
+	}
+	return w
+}
+
+// getRecordingWork returns the work of a single recording.
+func getRecordingWork(client *musicBrainz, recording gomusicbrainz.MBID) workCredits {
+	if !lookupWorks || recording == "" {
+		return workCredits{}
+	}
+
+	var result struct {
+		XMLName   xml.Name      `xml:"metadata"`
+		Relations []xmlRelation `xml:"recording>relation-list>relation"`
+	}
+	err := client.getXML("looking up the works", "/recording/"+string(recording),
+		url.Values{"inc": {"work-rels+work-level-rels+artist-rels"}}, &result)
+	if err != nil {
+		fmt.Printf("Warning: couldn't get the works of the recording: %v\n", err)
+		return workCredits{}
+	}
+	return xmlWork(result.Relations)
+}
+
+func appendMissing(list []string, s string) []string {
+	if s == "" {
+		return list
+	}
+	for _, v := range list {
+		if v == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/michiwend/gomusicbrainz"
+)
+
+const workReleaseXML = `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://musicbrainz.org/ns/mmd-2.0#">
+<release id="rel"><title>Symphonies</title>
+<medium-list count="1"><medium><position>1</position><track-list count="2">
+<track id="t1"><position>1</position><number>1</number><recording id="r1"><title>I. Allegro</title>
+	<relation-list target-type="artist">
+		<relation type="conductor"><target>a0</target><artist id="a0"><name>Conductor</name></artist></relation>
+	</relation-list>
+	<relation-list target-type="work">
+		<relation type="performance"><target>w1</target>
+			<work id="w1"><title>Symphony No. 5: I. Allegro</title>
+				<relation-list target-type="artist">
+					<relation type="composer"><target>a1</target><direction>backward</direction><artist id="a1"><name>Ludwig van Beethoven</name></artist></relation>
+				</relation-list>
+				<relation-list target-type="work">
+					<relation type="parts"><target>w0</target><direction>backward</direction><ordering-key>1</ordering-key><work id="w0"><title>Symphony No. 5</title></work></relation>
+				</relation-list>
+			</work>
+		</relation>
+	</relation-list>
+</recording></track>
+<track id="t2"><position>2</position><number>2</number><recording id="r2"><title>Song</title>
+	<relation-list target-type="work">
+		<relation type="performance"><target>w2</target>
+			<work id="w2"><title>Song</title>
+				<relation-list target-type="artist">
+					<relation type="writer"><target>a2</target><direction>backward</direction><target-credit>Writer</target-credit><artist id="a2"><name>Someone</name></artist></relation>
+					<relation type="lyricist"><target>a3</target><direction>backward</direction><artist id="a3"><name>Poet</name></artist></relation>
+				</relation-list>
+			</work>
+		</relation>
+	</relation-list>
+</recording></track>
+</track-list></medium></medium-list></release>
+</metadata>`
+
+func TestLookupReleaseWorks(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if inc := r.URL.Query().Get("inc"); !strings.Contains(inc, "work-level-rels") {
+			t.Errorf("the works weren't included: %q", inc)
+		}
+		fmt.Fprint(w, workReleaseXML)
+	}))
+	defer srv.Close()
+
+	client := newMusicBrainz(srv.URL, "test", time.Second, 100, responseCache{})
+	_, rc, err := client.lookupRelease("rel", append([]string{"recordings"}, workIncludes...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("%d requests, want 1", requests)
+	}
+
+	want := map[string]workCredits{
+		"t1": {workID: "w1", work: "Symphony No. 5", movement: "Symphony No. 5: I. Allegro", movementNumber: 1,
+			composers: []string{"Ludwig van Beethoven"}},
+		"t2": {workID: "w2", work: "Song", composers: []string{"Writer"}, lyricists: []string{"Poet"}},
+	}
+	for id, w := range want {
+		if got := rc.works[gomusicbrainz.MBID(id)]; !reflect.DeepEqual(got, w) {
+			t.Errorf("works of %s = %+v, want %+v", id, got, w)
+		}
+	}
+}
+
+func TestGetRecordingWork(t *testing.T) {
+	defer func(works bool) { lookupWorks = works }(lookupWorks)
+	lookupWorks = true
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/recording/r2" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<metadata xmlns="http://musicbrainz.org/ns/mmd-2.0#"><recording id="r2"><title>Medley</title>
+	<relation-list target-type="work">
+		<relation type="performance"><target>w2</target>
+			<work id="w2"><title>Song</title>
+				<relation-list target-type="artist">
+					<relation type="composer"><target>a2</target><direction>backward</direction><artist id="a2"><name>Composer</name></artist></relation>
+				</relation-list>
+			</work>
+		</relation>
+		<relation type="performance"><target>w3</target>
+			<work id="w3"><title>Other Song</title>
+				<relation-list target-type="artist">
+					<relation type="lyricist"><target>a3</target><direction>backward</direction><artist id="a3"><name>Poet</name></artist></relation>
+					<relation type="composer"><target>a2</target><direction>backward</direction><artist id="a2"><name>Composer</name></artist></relation>
+				</relation-list>
+			</work>
+		</relation>
+	</relation-list>
+</recording></metadata>`)
+	}))
+	defer srv.Close()
+
+	client := newMusicBrainz(srv.URL, "test", time.Second, 100, responseCache{})
+	want := workCredits{workID: "w2", work: "Song", composers: []string{"Composer"}, lyricists: []string{"Poet"}}
+	if got := getRecordingWork(client, "r2"); !reflect.DeepEqual(got, want) {
+		t.Errorf("work = %+v, want %+v", got, want)
+	}
+	if got := getRecordingWork(client, "unknown"); !reflect.DeepEqual(got, workCredits{}) {
+		t.Errorf("work of an unknown recording = %+v", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"net/url"
 	"os"
@@ -17,10 +18,26 @@ import (
 	"github.com/pkg/errors"
 )
 
-func getTracks(url string) ([]float64, error) {
+// getTracks returns the track offsets and titles of an album video. The
+// timestamps are parsed from the description, the links in the description of
+// the video page are only used as a fallback and carry no titles.
+func getTracks(url string, vid *ytdl.VideoInfo) ([]float64, []string, error) {
+	if tracks, titles := parseTimestamps(vid.Description, vid.Duration); len(tracks) > 0 {
+		return tracks, titles, nil
+	}
+
+	tracks, err := scrapeTracks(url)
+	return tracks, nil, err
+}
+
+func scrapeTracks(url string) ([]float64, error) {
 	var tracks []float64
 
-	doc, err := goquery.NewDocument(url)
+	var doc *goquery.Document
+	err := netRetry.do("getting the video page", func() (err error) {
+		doc, err = goquery.NewDocument(url)
+		return err
+	})
 	if err != nil {
 		return tracks, err
 	}
@@ -37,6 +54,16 @@ func getTracks(url string) ([]float64, error) {
 	return tracks, nil
 }
 
+// getVideoInfo is ytdl.GetVideoInfo with retries.
+func getVideoInfo(url string) (*ytdl.VideoInfo, error) {
+	var vid *ytdl.VideoInfo
+	err := netRetry.do("getting the video info", func() (err error) {
+		vid, err = ytdl.GetVideoInfo(url)
+		return err
+	})
+	return vid, err
+}
+
 func getContentLength(url *url.URL) (int, error) {
 	// try to get the length from the url query params
 	if clString, ok := url.Query()["clen"]; ok {
@@ -44,55 +71,238 @@ func getContentLength(url *url.URL) (int, error) {
 	}
 
 	// try to get the length from the http header
-	response, err := http.Head(url.String())
+	var response *http.Response
+	err := netRetry.do("getting the content length", func() (err error) {
+		response, err = http.Head(url.String())
+		if err != nil {
+			return err
+		}
+		response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return newStatusError(response)
+		}
+		return nil
+	})
 	if err != nil {
 		return 0, errors.Wrap(err, "couldn't get content length from http header")
 	}
 
-	if response.StatusCode != http.StatusOK {
-		return 0, errors.New("server returned non-200 status: " + response.Status)
-	}
-
 	return strconv.Atoi(response.Header.Get("Content-Length"))
 }
 
-func download(vid *ytdl.VideoInfo, outfile string) error {
-	os.MkdirAll(filepath.Dir(outfile), 0777)
-	// get best AudioBitrate
-	var format ytdl.Format
-	for _, v := range vid.Formats.Best(ytdl.FormatAudioBitrateKey) {
-		if v.AudioBitrate > format.AudioBitrate {
-			format = v
+// download downloads vid to outfile, or into the cache if it is enabled. It
+// returns the path of the downloaded video.
+func download(vid *ytdl.VideoInfo, outfile string) (string, error) {
+	format, err := audioFormat.selectFormat(vid.Formats)
+	if err != nil {
+		return "", err
+	}
+
+	// the tracks are converted into the folder of outfile even if the video
+	// comes from the cache
+	if err := os.MkdirAll(filepath.Dir(outfile), 0777); err != nil {
+		return "", errors.Wrap(err, "couldn't create the album folder")
+	}
+	if path, ok := videoCache.get(vid.ID, format.Itag); ok {
+		fmt.Println("Using the cached video")
+		return path, nil
+	}
+	if videoCache.enabled() {
+		outfile = videoCache.path(vid.ID, format.Itag)
+		if err := os.MkdirAll(filepath.Dir(outfile), 0777); err != nil {
+			return "", errors.Wrap(err, "couldn't create the cache folder")
 		}
 	}
 
 	// get downloadURL and ...
 	dlURL, err := vid.GetDownloadURL(format)
 	if err != nil {
-		return errors.Wrap(err, "couldn't get download url")
+		return "", errors.Wrap(err, "couldn't get download url")
 	}
 	//... get the content length
 	clen, err := getContentLength(dlURL)
+	if err != nil {
+		return "", errors.Wrap(err, "getContentLength failed")
+	}
+
+	if err := downloadFile(dlURL.String(), outfile, partPath(outfile, vid.ID, format.Itag), int64(clen)); err != nil {
+		return "", err
+	}
+	return outfile, videoCache.evict(outfile)
+}
+
+// canStream reports whether ffmpeg can read format from a pipe. The index of
+// mp4 files may be at the end of the file, so ffmpeg has to seek to probe them.
+func canStream(format ytdl.Format) bool {
+	return format.Extension != "mp4" && format.Extension != "3gp"
+}
+
+type countWriter struct {
+	w   io.Writer
+	n   int64
+	err error // the last error of w
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	if err != nil {
+		cw.err = err
+	}
+	return n, err
+}
+
+// downloadStream downloads format of vid into w. Interrupted transfers are
+// continued with a range request, so w gets every byte exactly once.
+func downloadStream(vid *ytdl.VideoInfo, format ytdl.Format, w io.Writer) error {
+	dlURL, err := vid.GetDownloadURL(format)
+	if err != nil {
+		return errors.Wrap(err, "couldn't get download url")
+	}
+	clen, err := getContentLength(dlURL)
 	if err != nil {
 		return errors.Wrap(err, "getContentLength failed")
 	}
 
-	file, err := os.Create(outfile)
+	bar := pb.New(clen).SetUnits(pb.U_BYTES)
+	bar.ShowTimeLeft = true
+	bar.ShowSpeed = true
+	bar.Start()
+
+	cw := &countWriter{w: io.MultiWriter(w, bar)}
+	err = netRetry.do("download", func() error {
+		req, err := http.NewRequest("GET", dlURL.String(), nil)
+		if err != nil {
+			return err
+		}
+		if cw.n > 0 {
+			req.Header.Set("Range", fmt.Sprintf("bytes=%d-", cw.n))
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		switch {
+		case cw.n > 0 && resp.StatusCode == http.StatusOK:
+			return errors.New("the server doesn't support resuming the download")
+		case resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent:
+			return newStatusError(resp)
+		}
+
+		if _, err := io.Copy(cw, resp.Body); err != nil {
+			if cw.err != nil {
+				return &writeError{cw.err}
+			}
+			return err
+		}
+		if cw.n != int64(clen) {
+			return errors.Wrapf(io.ErrUnexpectedEOF, "download incomplete: got %d of %d bytes", cw.n, clen)
+		}
+		return nil
+	})
+	bar.Finish()
+	return errors.Wrap(err, "download failed")
+}
+
+// partPath returns the file a download into outfile is written to until it is
+// complete. Outside of the cache outfile is named after the album, so the
+// part file is named after the video and format to never resume another one.
+func partPath(outfile, videoID string, itag int) string {
+	if videoCache.enabled() {
+		return outfile + ".part"
+	}
+	return filepath.Join(filepath.Dir(outfile), fmt.Sprintf("%s-%d.part", videoID, itag))
+}
+
+// downloadFile downloads the file at url to outfile. The data is written to
+// partFile, which is resumed with a range request if it already exists.
+// outfile is only created once the part file has the expected length of clen
+// bytes, so a broken download never ends up in ffmpeg.
+func downloadFile(url, outfile, partFile string, clen int64) error {
+	file, err := os.OpenFile(partFile, os.O_CREATE|os.O_WRONLY, 0666)
 	if err != nil {
 		return errors.Wrap(err, "couldn't create the video file")
 	}
 	defer file.Close()
 
 	// create and start bar
-	bar := pb.New(clen).SetUnits(pb.U_BYTES)
+	bar := pb.New64(clen).SetUnits(pb.U_BYTES)
 	bar.ShowTimeLeft = true
 	bar.ShowSpeed = true
 	bar.Start()
-	defer bar.Finish()
 
-	// create multi writer
-	writer := io.MultiWriter(file, bar)
+	// every attempt continues where the last one stopped
+	err = netRetry.do("download", func() error {
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+		offset := info.Size()
+		if offset > clen {
+			offset = 0
+		}
 
-	err = vid.Download(format, writer)
-	return errors.Wrap(err, "download failed")
+		bar.Set64(offset)
+		if offset < clen {
+			if err := fetchRange(url, file, offset, bar); err != nil {
+				return err
+			}
+		}
+
+		if info, err = file.Stat(); err != nil {
+			return err
+		}
+		if info.Size() != clen {
+			return errors.Wrapf(io.ErrUnexpectedEOF, "download incomplete: got %d of %d bytes", info.Size(), clen)
+		}
+		return nil
+	})
+	bar.Finish()
+	if err != nil {
+		return errors.Wrap(err, "download failed")
+	}
+
+	file.Close()
+	return errors.Wrap(os.Rename(partFile, outfile), "couldn't rename the video file")
+}
+
+// fetchRange writes the content of url from offset on into file. If the server
+// ignores the range header, the file is written from the start.
+func fetchRange(url string, file *os.File, offset int64, bar *pb.ProgressBar) error {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		offset = 0
+		bar.Set64(0)
+	default:
+		return newStatusError(resp)
+	}
+
+	if err := file.Truncate(offset); err != nil {
+		return err
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(io.MultiWriter(file, bar), resp.Body)
+	return err
 }
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rangeServer serves content and honours range requests. The first response
+// is cut off after cut bytes if cut is positive.
+type rangeServer struct {
+	content     []byte
+	cut         int
+	ignoreRange bool
+	ranges      []string
+}
+
+func (s *rangeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rng := r.Header.Get("Range")
+	s.ranges = append(s.ranges, rng)
+
+	start := 0
+	if rng != "" && !s.ignoreRange {
+		start, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(s.content)-1, len(s.content)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)-start))
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.Header().Set("Content-Length", strconv.Itoa(len(s.content)))
+	}
+
+	body := s.content[start:]
+	if s.cut > 0 {
+		// the client sees an unexpected EOF
+		body, s.cut = body[:s.cut], 0
+	}
+	w.Write(body)
+}
+
+// fastRetries makes retries immediate, the returned function restores the
+// retry policy.
+func fastRetries() func() {
+	saved := netRetry
+	netRetry.backoff, netRetry.jitter = time.Millisecond, 0
+	return func() { netRetry = saved }
+}
+
+func tempDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "ymdl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestDownloadFileResumes(t *testing.T) {
+	defer fastRetries()()
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+
+	for _, tc := range []struct {
+		name       string
+		server     rangeServer
+		part       []byte // the content of the part file before the download
+		wantRanges []string
+	}{
+		{"complete", rangeServer{}, nil, []string{""}},
+		{"interrupted", rangeServer{cut: 4000}, nil, []string{"", "bytes=4000-"}},
+		{"part file", rangeServer{}, content[:2500], []string{"bytes=2500-"}},
+		{"range ignored", rangeServer{ignoreRange: true}, []byte("garbage"), []string{"bytes=7-"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.server.content = content
+			srv := httptest.NewServer(&tc.server)
+			defer srv.Close()
+
+			dir, cleanup := tempDir(t)
+			defer cleanup()
+			outfile := filepath.Join(dir, "video")
+			if tc.part != nil {
+				if err := ioutil.WriteFile(outfile+".part", tc.part, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := downloadFile(srv.URL, outfile, outfile+".part", int64(len(content))); err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(outfile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Errorf("downloaded %d bytes that differ from the content", len(got))
+			}
+			if _, err := os.Stat(outfile + ".part"); !os.IsNotExist(err) {
+				t.Errorf("the part file is left behind: %v", err)
+			}
+			if fmt.Sprint(tc.server.ranges) != fmt.Sprint(tc.wantRanges) {
+				t.Errorf("ranges = %q, want %q", tc.server.ranges, tc.wantRanges)
+			}
+		})
+	}
+}
+
+func TestDownloadFileKeepsIncompleteParts(t *testing.T) {
+	defer fastRetries()()
+	netRetry.attempts = 1
+
+	content := bytes.Repeat([]byte("x"), 1000)
+	srv := httptest.NewServer(&rangeServer{content: content, cut: 400})
+	defer srv.Close()
+
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	outfile := filepath.Join(dir, "video")
+	if err := downloadFile(srv.URL, outfile, outfile+".part", int64(len(content))); err == nil {
+		t.Fatal("an incomplete download succeeded")
+	}
+	if _, err := os.Stat(outfile); !os.IsNotExist(err) {
+		t.Errorf("an incomplete download was renamed: %v", err)
+	}
+	if info, err := os.Stat(outfile + ".part"); err != nil || info.Size() != 400 {
+		t.Errorf("the part file wasn't kept for resuming: %v", err)
+	}
+}
+
+func TestPartPath(t *testing.T) {
+	defer func(c downloadCache) { videoCache = c }(videoCache)
+	dir, cleanup := tempDir(t)
+	defer cleanup()
+	outfile := filepath.Join(dir, "Album")
+
+	videoCache = downloadCache{}
+	parts := map[string]bool{}
+	for _, video := range []struct {
+		id   string
+		itag int
+	}{{"aaaaaaaaaaa", 140}, {"aaaaaaaaaaa", 251}, {"bbbbbbbbbbb", 140}} {
+		part := partPath(outfile, video.id, video.itag)
+		if filepath.Dir(part) != dir || parts[part] {
+			t.Errorf("%s %d is downloaded to %s", video.id, video.itag, part)
+		}
+		parts[part] = true
+	}
+
+	videoCache = downloadCache{dir: dir}
+	cached := videoCache.path("aaaaaaaaaaa", 140)
+	if part := partPath(cached, "aaaaaaaaaaa", 140); part != cached+".part" {
+		t.Errorf("the cached video is downloaded to %s", part)
+	}
+}